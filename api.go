@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"slides/deck"
+)
+
+// The JSON API describes a loaded deck for tools built on top of it:
+//
+//	GET /api/deck       the whole deck
+//	GET /api/slides/{n} one slide, n being its number in reading order
+//
+// Both carry a version that changes whenever the schema changes in a way
+// that could break a client. Fields are only ever added within a version.
+
+// apiVersion is the version of the /api/deck and /api/slides schema.
+const apiVersion = 1
+
+type apiDeck struct {
+	Version     int              `json:"version"`
+	Title       string           `json:"title"`
+	Author      string           `json:"author,omitempty"`
+	Date        string           `json:"date,omitempty"`
+	Theme       string           `json:"theme"`
+	Transition  string           `json:"transition"`
+	Frontmatter deck.Frontmatter `json:"frontmatter"`
+	Split       deck.SplitRules  `json:"split"`
+	Sections    int              `json:"sections"` // number of top-level slides
+	Slides      []apiSlide       `json:"slides"`
+}
+
+type apiSlide struct {
+	Number          int        `json:"number"` // position in reading order, from 1
+	Label           string     `json:"label"`  // as the counter shows it, e.g. "3.2"
+	Section         int        `json:"section"`
+	Sub             int        `json:"sub"` // 0 for a top-level slide
+	Title           string     `json:"title"`
+	HTML            string     `json:"html"`
+	Text            string     `json:"text"`
+	NotesHTML       string     `json:"notes_html"`
+	Notes           string     `json:"notes"`
+	Class           string     `json:"class,omitempty"`
+	Layout          string     `json:"layout,omitempty"`
+	Background      string     `json:"background,omitempty"`
+	BackgroundImage string     `json:"background_image,omitempty"`
+	Source          *apiSource `json:"source,omitempty"` // absent for the theme's first and last slides
+}
+
+// apiSource is where a slide is written, with the file relative to the
+// deck's directory.
+type apiSource struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line"`
+}
+
+// newAPIDeck describes deck for the JSON API.
+func newAPIDeck(deck *deck.Deck) apiDeck {
+	d := apiDeck{
+		Version:     apiVersion,
+		Title:       deck.Title,
+		Author:      deck.Author,
+		Date:        deck.Date,
+		Theme:       deck.ThemeName,
+		Transition:  deck.Transition,
+		Frontmatter: deck.Frontmatter,
+		Split:       deck.Split,
+		Sections:    deck.Sections(),
+		Slides:      make([]apiSlide, len(deck.Slides)),
+	}
+	for i, slide := range deck.Slides {
+		d.Slides[i] = newAPISlide(deck, slide)
+	}
+	return d
+}
+
+func newAPISlide(deck *deck.Deck, slide deck.Slide) apiSlide {
+	s := apiSlide{
+		Number:          slide.Number,
+		Label:           slide.Label(),
+		Section:         slide.Section,
+		Sub:             slide.Sub,
+		Title:           slide.Title,
+		HTML:            string(slide.Content),
+		Text:            slide.Text,
+		NotesHTML:       string(slide.Notes),
+		Notes:           slide.NotesText,
+		Class:           slide.Class,
+		Layout:          slide.Layout,
+		Background:      slide.Background,
+		BackgroundImage: slide.BackgroundImage,
+	}
+	if slide.File != "" {
+		file := slide.File
+		if abs, err := filepath.Abs(file); err == nil && len(deck.Files) > 0 {
+			if rel, err := filepath.Rel(filepath.Dir(deck.Files[0]), abs); err == nil {
+				file = rel
+			}
+		}
+		s.Source = &apiSource{File: filepath.ToSlash(file), Line: slide.Line, EndLine: slide.EndLine}
+	}
+	return s
+}
+
+func (s *server) serveDeckAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, newAPIDeck(s.current()))
+}
+
+func (s *server) serveSlideAPI(w http.ResponseWriter, r *http.Request) {
+	deck := s.current()
+	arg := strings.TrimPrefix(r.URL.Path, "/api/slides/")
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(deck.Slides) {
+		http.Error(w, fmt.Sprintf("slide %q not found", arg), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, struct {
+		Version int `json:"version"`
+		apiSlide
+	}{apiVersion, newAPISlide(deck, deck.Slides[n-1])})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"slides/deck"
+	"slides/render"
+)
+
+// assetRefRegex finds asset links in HTML rendered from a deck loaded with
+// the relative asset base "assets/".
+var assetRefRegex = regexp.MustCompile(`(?:src|href)="assets/([^"?#]+)`)
+
+// buildSite writes the deck as plain files that any static web server can
+// host: index.html, presenter.html, style.css and the referenced assets.
+// The deck must have been loaded with the asset base "assets/".
+func buildSite(deck *deck.Deck, assetDir, outDir string, opts render.Options) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	var index, presenter bytes.Buffer
+	if err := render.HTML(&index, deck, opts); err != nil {
+		return fmt.Errorf("render slides: %w", err)
+	}
+	if err := render.Presenter(&presenter, deck, opts); err != nil {
+		return fmt.Errorf("render presenter view: %w", err)
+	}
+
+	files := map[string][]byte{
+		"index.html":     index.Bytes(),
+		"presenter.html": presenter.Bytes(),
+		"style.css":      []byte(render.CSS(deck.Theme)),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(outDir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	// Copy every asset the pages point at, keeping the directory layout
+	copied := make(map[string]bool)
+	for _, match := range assetRefRegex.FindAllStringSubmatch(index.String()+presenter.String(), -1) {
+		rel := filepath.FromSlash(html.UnescapeString(match[1]))
+		if copied[rel] {
+			continue
+		}
+		copied[rel] = true
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("asset %q points outside %s", match[1], assetDir)
+		}
+		err := copyFile(filepath.Join(assetDir, rel), filepath.Join(outDir, "assets", rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Broken links are the author's business; don't fail the whole build
+			log.Printf("Skipping missing asset %s", match[1])
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("copy asset: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
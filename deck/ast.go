@@ -0,0 +1,253 @@
+package deck
+
+import (
+	"strings"
+)
+
+// The markdown AST: a Document holds slides, a slide holds blocks and blocks
+// hold inlines. Every output format (HTML, PDF, PPTX) walks this tree rather
+// than re-parsing markdown.
+
+// Document is a parsed deck body.
+type Document struct {
+	Slides []*SlideNode
+}
+
+// SlideNode is one slide: its directives, what the audience sees and the
+// speaker notes.
+type SlideNode struct {
+	Meta   SlideMeta
+	Blocks []Block
+	Notes  []Block
+}
+
+// Block is a block-level node.
+type Block interface {
+	block()
+}
+
+// Heading is an ATX (#) or setext (underlined) heading.
+type Heading struct {
+	Level   int
+	Inlines []Inline
+}
+
+// Paragraph is a run of text lines.
+type Paragraph struct {
+	Inlines []Inline
+}
+
+// CodeBlock is a fenced or indented code block. Info is the full info string
+// after the opening fence; Lang is its first word. Steps lists the line
+// numbers to highlight from a {3,5-7} attribute, one set per step when the
+// attribute is a sequence like {1|3-4|6}.
+type CodeBlock struct {
+	Info  string
+	Lang  string
+	Code  string
+	Steps [][]int
+}
+
+// ThematicBreak is a horizontal rule.
+type ThematicBreak struct{}
+
+// Pause is a ". . ." paragraph. The blocks after it are revealed one step
+// later than the ones before.
+type Pause struct{}
+
+// BlockQuote is a > quoted section.
+type BlockQuote struct {
+	Blocks []Block
+}
+
+// List is a bullet or ordered list. Tight lists render items without <p>.
+// Incremental lists, written with "+" bullets, reveal one item per step.
+type List struct {
+	Ordered     bool
+	Start       int
+	Tight       bool
+	Incremental bool
+	Items       []*ListItem
+}
+
+// Columns lays out its columns side by side. It comes from a ::: columns
+// container holding ::: col containers.
+type Columns struct {
+	Columns []*Column
+}
+
+// Column is one column of Columns. Width is a CSS grid track, either a share
+// of the free space like "1fr" (the default) or a percentage like "40%".
+type Column struct {
+	Width  string
+	Blocks []Block
+}
+
+// Table is a GitHub-flavored markdown table. Align has one entry per column:
+// "left", "center", "right" or "" when the delimiter row sets none. Every row
+// has exactly one cell per column.
+type Table struct {
+	Align  []string
+	Header []*TableCell
+	Rows   [][]*TableCell
+}
+
+// TableCell is one cell of a Table.
+type TableCell struct {
+	Inlines []Inline
+}
+
+// ListItem is one entry of a List and may contain any blocks, including
+// nested lists.
+type ListItem struct {
+	Blocks []Block
+}
+
+func (*Heading) block()       {}
+func (*Paragraph) block()     {}
+func (*CodeBlock) block()     {}
+func (*ThematicBreak) block() {}
+func (*Pause) block()         {}
+func (*BlockQuote) block()    {}
+func (*List) block()          {}
+func (*Table) block()         {}
+func (*Columns) block()       {}
+
+// Inline is an inline-level node.
+type Inline interface {
+	inline()
+}
+
+// Text is literal text.
+type Text struct {
+	Value string
+}
+
+// SoftBreak is a line ending inside a paragraph.
+type SoftBreak struct{}
+
+// HardBreak is a forced line break (two trailing spaces or a backslash).
+type HardBreak struct{}
+
+// CodeSpan is `inline code`.
+type CodeSpan struct {
+	Code string
+}
+
+// Emphasis is *emphasized* text.
+type Emphasis struct {
+	Children []Inline
+}
+
+// Strong is **strongly emphasized** text.
+type Strong struct {
+	Children []Inline
+}
+
+// Link is [text](dest "title").
+type Link struct {
+	Dest     string
+	Title    string
+	Children []Inline
+}
+
+// Image is ![alt](src "title"); the children form the alt text.
+type Image struct {
+	Src      string
+	Title    string
+	Children []Inline
+}
+
+func (*Text) inline()      {}
+func (*SoftBreak) inline() {}
+func (*HardBreak) inline() {}
+func (*CodeSpan) inline()  {}
+func (*Emphasis) inline()  {}
+func (*Strong) inline()    {}
+func (*Link) inline()      {}
+func (*Image) inline()     {}
+
+// PlainText flattens inlines to their text content, e.g. for alt attributes.
+func PlainText(inlines []Inline) string {
+	var b strings.Builder
+	var walk func([]Inline)
+	walk = func(nodes []Inline) {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case *Text:
+				b.WriteString(n.Value)
+			case *CodeSpan:
+				b.WriteString(n.Code)
+			case *SoftBreak, *HardBreak:
+				b.WriteString(" ")
+			case *Emphasis:
+				walk(n.Children)
+			case *Strong:
+				walk(n.Children)
+			case *Link:
+				walk(n.Children)
+			case *Image:
+				walk(n.Children)
+			}
+		}
+	}
+	walk(inlines)
+	return b.String()
+}
+
+// blocksText flattens blocks to their text content, one line per paragraph,
+// heading, list item or table row, with code blocks kept whole. Search
+// indexes slides by it.
+func blocksText(blocks []Block) string {
+	var lines []string
+	var walk func([]Block)
+	walk = func(blocks []Block) {
+		for _, b := range blocks {
+			switch b := b.(type) {
+			case *Heading:
+				lines = append(lines, PlainText(b.Inlines))
+			case *Paragraph:
+				lines = append(lines, PlainText(b.Inlines))
+			case *CodeBlock:
+				lines = append(lines, strings.TrimRight(b.Code, "\n"))
+			case *BlockQuote:
+				walk(b.Blocks)
+			case *List:
+				for _, item := range b.Items {
+					walk(item.Blocks)
+				}
+			case *Table:
+				for _, row := range append([][]*TableCell{b.Header}, b.Rows...) {
+					cells := make([]string, len(row))
+					for i, cell := range row {
+						cells[i] = PlainText(cell.Inlines)
+					}
+					lines = append(lines, strings.Join(cells, " | "))
+				}
+			case *Columns:
+				for _, col := range b.Columns {
+					walk(col.Blocks)
+				}
+			}
+		}
+	}
+	walk(blocks)
+	return strings.Join(lines, "\n")
+}
+
+// firstHeading is the text of the first heading among blocks, or "".
+func firstHeading(blocks []Block) string {
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case *Heading:
+			return PlainText(b.Inlines)
+		case *Columns:
+			for _, col := range b.Columns {
+				if h := firstHeading(col.Blocks); h != "" {
+					return h
+				}
+			}
+		}
+	}
+	return ""
+}
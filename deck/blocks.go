@@ -0,0 +1,983 @@
+package deck
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The block parser follows the CommonMark parsing strategy: every line is
+// first matched against the open container blocks (block quotes and list
+// items), then checked for new block starts, and whatever is left is added to
+// the innermost open leaf block. Inline content is parsed once all blocks are
+// closed, when link reference definitions are known. Raw HTML is not
+// supported and is shown as text.
+
+// nodeKind identifies an open block while parsing.
+type nodeKind int
+
+const (
+	nodeDocument nodeKind = iota
+	nodeBlockQuote
+	nodeList
+	nodeItem
+	nodeParagraph
+	nodeHeading
+	nodeCode
+	nodeBreak
+	nodeTable
+	nodeDiv // ::: fenced container; info holds the text after the fence
+)
+
+// listData describes a list marker. Items are added to the open list only if
+// their marker matches.
+type listData struct {
+	ordered      bool
+	bullet       byte // '-', '+' or '*' for bullet lists, '.' or ')' for ordered
+	start        int
+	markerOffset int
+	padding      int
+}
+
+// node is a block under construction.
+type node struct {
+	kind     nodeKind
+	parent   *node
+	children []*node
+	open     bool
+	line     int // line the block started on
+
+	lastLineBlank   bool
+	lastLineChecked bool
+
+	content strings.Builder // raw text of leaf blocks
+	level   int             // heading level
+	list    listData        // list and item marker
+	tight   bool
+
+	fenced      bool
+	fenceChar   byte
+	fenceLen    int
+	fenceOffset int
+	info        string
+	literal     string
+
+	align  []string   // table column alignment
+	header []string   // table header cells
+	rows   [][]string // table body cells
+}
+
+func (n *node) lastChild() *node {
+	if len(n.children) == 0 {
+		return nil
+	}
+	return n.children[len(n.children)-1]
+}
+
+func (n *node) remove() {
+	siblings := n.parent.children
+	for i, c := range siblings {
+		if c == n {
+			n.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+			return
+		}
+	}
+}
+
+// canContain reports whether a block of the given kind may hold a child.
+func canContain(parent, child nodeKind) bool {
+	switch parent {
+	case nodeDocument, nodeBlockQuote, nodeItem, nodeDiv:
+		return child != nodeItem
+	case nodeList:
+		return child == nodeItem
+	}
+	return false
+}
+
+// linkRef is the target of a link reference definition.
+type linkRef struct {
+	dest, title string
+}
+
+type blockParser struct {
+	doc  *node
+	tip  *node
+	refs map[string]linkRef
+
+	oldtip               *node
+	lastMatchedContainer *node
+	allClosed            bool
+
+	lineNumber           int
+	line                 string
+	offset               int
+	column               int
+	nextNonspace         int
+	nextNonspaceColumn   int
+	indent               int
+	indented             bool
+	blank                bool
+	partiallyConsumedTab bool
+}
+
+var (
+	atxHeadingRegex     = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	atxClosingRegex     = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	codeFenceRegex      = regexp.MustCompile("^(?:`{3,}|~{3,})")
+	closingFenceRegex   = regexp.MustCompile("^(?:`{3,}|~{3,})[ \t]*$")
+	setextRegex         = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	thematicBreakRegex  = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:_[ \t]*){3,}|(?:-[ \t]*){3,})$`)
+	bulletMarkerRegex   = regexp.MustCompile(`^[*+-]`)
+	orderedMarkerRegex  = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	trailingBlankRegex  = regexp.MustCompile(`(\n[ \t]*)+$`)
+	tableDelimiterRegex = regexp.MustCompile(`^:?-+:?$`)
+	divFenceRegex       = regexp.MustCompile(`^:{3,}[ \t]*(columns|col|column)(?:[ \t]+(\d+(?:\.\d+)?(?:fr|%)?))?[ \t]*:*[ \t]*$`)
+	closingDivRegex     = regexp.MustCompile(`^:{3,}[ \t]*$`)
+	maybeSpecialBytes   = "#`~*+_=<>0123456789-|:"
+	lineEndingsReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\x00", "�")
+)
+
+// ParseBlocks parses markdown into a block tree with parsed inlines.
+func ParseBlocks(md string) []Block {
+	doc := &node{kind: nodeDocument, open: true}
+	p := &blockParser{doc: doc, tip: doc, oldtip: doc, lastMatchedContainer: doc, refs: make(map[string]linkRef)}
+
+	md = lineEndingsReplacer.Replace(md)
+	lines := strings.Split(md, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		p.incorporateLine(line)
+	}
+	for p.tip != nil {
+		p.finalize(p.tip)
+	}
+	return p.convert(doc.children)
+}
+
+func (p *blockParser) peek(i int) byte {
+	if i < len(p.line) {
+		return p.line[i]
+	}
+	return 0
+}
+
+func (p *blockParser) findNextNonspace() {
+	i, cols := p.offset, p.column
+	for i < len(p.line) {
+		switch p.line[i] {
+		case ' ':
+			i++
+			cols++
+			continue
+		case '\t':
+			i++
+			cols += 4 - cols%4
+			continue
+		}
+		break
+	}
+	p.blank = i >= len(p.line)
+	p.nextNonspace = i
+	p.nextNonspaceColumn = cols
+	p.indent = cols - p.column
+	p.indented = p.indent >= 4
+}
+
+// advanceOffset moves forward count bytes, or count columns when columns is
+// set, in which case a tab may be consumed only partially.
+func (p *blockParser) advanceOffset(count int, columns bool) {
+	for count > 0 && p.offset < len(p.line) {
+		if p.line[p.offset] != '\t' {
+			p.partiallyConsumedTab = false
+			p.offset++
+			p.column++
+			count--
+			continue
+		}
+		toTab := 4 - p.column%4
+		if !columns {
+			p.partiallyConsumedTab = false
+			p.column += toTab
+			p.offset++
+			count--
+			continue
+		}
+		p.partiallyConsumedTab = toTab > count
+		n := toTab
+		if count < n {
+			n = count
+		}
+		p.column += n
+		if !p.partiallyConsumedTab {
+			p.offset++
+		}
+		count -= n
+	}
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+	p.column = p.nextNonspaceColumn
+	p.partiallyConsumedTab = false
+}
+
+// addLine appends the rest of the current line to the tip.
+func (p *blockParser) addLine() {
+	if p.partiallyConsumedTab {
+		p.offset++
+		p.tip.content.WriteString(strings.Repeat(" ", 4-p.column%4))
+	}
+	if p.offset < len(p.line) {
+		p.tip.content.WriteString(p.line[p.offset:])
+	}
+	p.tip.content.WriteByte('\n')
+}
+
+// addChild opens a new block, closing blocks that can't contain it.
+func (p *blockParser) addChild(kind nodeKind) *node {
+	for !canContain(p.tip.kind, kind) {
+		p.finalize(p.tip)
+	}
+	n := &node{kind: kind, parent: p.tip, open: true, line: p.lineNumber}
+	p.tip.children = append(p.tip.children, n)
+	p.tip = n
+	return n
+}
+
+func (p *blockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldtip != p.lastMatchedContainer {
+		parent := p.oldtip.parent
+		p.finalize(p.oldtip)
+		p.oldtip = parent
+	}
+	p.allClosed = true
+}
+
+// Results of continuing an open block or starting a new one.
+const (
+	matched  = iota // the block continues / a container was started
+	noMatch         // the block is closed / nothing started
+	consumed        // the line was fully handled / a leaf was started
+)
+
+func (p *blockParser) continues(n *node) int {
+	switch n.kind {
+	case nodeBlockQuote:
+		if p.indented || p.peek(p.nextNonspace) != '>' {
+			return noMatch
+		}
+		p.advanceNextNonspace()
+		p.advanceOffset(1, false)
+		if c := p.peek(p.offset); c == ' ' || c == '\t' {
+			p.advanceOffset(1, true)
+		}
+	case nodeItem:
+		switch {
+		case p.blank:
+			if len(n.children) == 0 {
+				// An item can begin with at most one blank line
+				return noMatch
+			}
+			p.advanceNextNonspace()
+		case p.indent >= n.list.markerOffset+n.list.padding:
+			p.advanceOffset(n.list.markerOffset+n.list.padding, true)
+		default:
+			return noMatch
+		}
+	case nodeCode:
+		if n.fenced {
+			rest := p.line[p.nextNonspace:]
+			if p.indent <= 3 && len(rest) > 0 && rest[0] == n.fenceChar {
+				if m := closingFenceRegex.FindString(rest); m != "" && len(strings.TrimRight(m, " \t")) >= n.fenceLen {
+					p.finalize(n)
+					return consumed
+				}
+			}
+			for i := n.fenceOffset; i > 0; i-- {
+				if c := p.peek(p.offset); c != ' ' && c != '\t' {
+					break
+				}
+				p.advanceOffset(1, true)
+			}
+		} else {
+			switch {
+			case p.indent >= 4:
+				p.advanceOffset(4, true)
+			case p.blank:
+				p.advanceNextNonspace()
+			default:
+				return noMatch
+			}
+		}
+	case nodeParagraph, nodeTable:
+		if p.blank {
+			return noMatch
+		}
+	case nodeDiv:
+		// A closing fence belongs to the innermost open div, and to none
+		// while a fenced code block is open inside
+		if p.indent <= 3 && closingDivRegex.MatchString(p.line[p.nextNonspace:]) && !hasOpenFence(n) {
+			for p.tip != n {
+				p.finalize(p.tip)
+			}
+			p.finalize(n)
+			return consumed
+		}
+	case nodeHeading, nodeBreak:
+		return noMatch
+	}
+	return matched
+}
+
+func (p *blockParser) incorporateLine(line string) {
+	container := p.doc
+	p.oldtip = p.tip
+	p.offset, p.column = 0, 0
+	p.blank, p.partiallyConsumedTab = false, false
+	p.lineNumber++
+	p.line = line
+
+	// Match the line against the open containers
+	allMatched := true
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		container = last
+		p.findNextNonspace()
+		switch p.continues(container) {
+		case noMatch:
+			container = container.parent
+			allMatched = false
+		case consumed:
+			return
+		}
+		if !allMatched {
+			break
+		}
+	}
+	p.allClosed = container == p.oldtip
+	p.lastMatchedContainer = container
+
+	// Look for new block starts
+	matchedLeaf := container.kind != nodeParagraph && acceptsLines(container.kind)
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !strings.ContainsRune(maybeSpecialBytes, rune(p.peek(p.nextNonspace))) {
+			p.advanceNextNonspace()
+			break
+		}
+		res := noMatch
+		for _, start := range blockStarts {
+			if res = start(p, container); res != noMatch {
+				break
+			}
+		}
+		if res == noMatch {
+			p.advanceNextNonspace()
+			break
+		}
+		container = p.tip
+		if res == consumed {
+			matchedLeaf = true
+		}
+	}
+
+	// What remains is content: a lazy paragraph continuation or text for the
+	// innermost block
+	if !p.allClosed && !p.blank && p.tip.kind == nodeParagraph {
+		p.addLine()
+		return
+	}
+	p.closeUnmatchedBlocks()
+	if p.blank && container.lastChild() != nil {
+		container.lastChild().lastLineBlank = true
+	}
+	lastLineBlank := p.blank && !(container.kind == nodeBlockQuote ||
+		container.kind == nodeCode && container.fenced ||
+		container.kind == nodeItem && len(container.children) == 0 && container.line == p.lineNumber)
+	for c := container; c != nil; c = c.parent {
+		c.lastLineBlank = lastLineBlank
+	}
+	switch {
+	case acceptsLines(container.kind):
+		p.addLine()
+	case container.kind == nodeTable:
+		if p.offset < len(p.line) {
+			p.addLine()
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(nodeParagraph)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+func acceptsLines(kind nodeKind) bool {
+	return kind == nodeParagraph || kind == nodeCode
+}
+
+// blockStarts are tried in order on the unmatched rest of a line.
+var blockStarts = []func(*blockParser, *node) int{
+	startBlockQuote,
+	startATXHeading,
+	startFencedCode,
+	startFencedDiv,
+	startTable,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startIndentedCode,
+}
+
+func startBlockQuote(p *blockParser, _ *node) int {
+	if p.indented || p.peek(p.nextNonspace) != '>' {
+		return noMatch
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(1, false)
+	if c := p.peek(p.offset); c == ' ' || c == '\t' {
+		p.advanceOffset(1, true)
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(nodeBlockQuote)
+	return matched
+}
+
+func startATXHeading(p *blockParser, _ *node) int {
+	if p.indented {
+		return noMatch
+	}
+	m := atxHeadingRegex.FindString(p.line[p.nextNonspace:])
+	if m == "" {
+		return noMatch
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(len(m), false)
+	p.closeUnmatchedBlocks()
+	h := p.addChild(nodeHeading)
+	h.level = len(strings.TrimRight(m, " \t"))
+	h.content.WriteString(atxClosingRegex.ReplaceAllString(p.line[p.offset:], ""))
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return consumed
+}
+
+func startFencedCode(p *blockParser, _ *node) int {
+	if p.indented {
+		return noMatch
+	}
+	rest := p.line[p.nextNonspace:]
+	m := codeFenceRegex.FindString(rest)
+	if m == "" || m[0] == '`' && strings.Contains(rest[len(m):], "`") {
+		return noMatch
+	}
+	p.closeUnmatchedBlocks()
+	c := p.addChild(nodeCode)
+	c.fenced = true
+	c.fenceChar = m[0]
+	c.fenceLen = len(m)
+	c.fenceOffset = p.indent
+	p.advanceNextNonspace()
+	p.advanceOffset(len(m), false)
+	return consumed
+}
+
+// startFencedDiv opens a ::: columns or ::: col container. The width after
+// col is a grid track: "2" or "2fr" for a share of the free space, or a
+// percentage.
+func startFencedDiv(p *blockParser, _ *node) int {
+	if p.indented {
+		return noMatch
+	}
+	m := divFenceRegex.FindStringSubmatch(p.line[p.nextNonspace:])
+	if m == nil {
+		return noMatch
+	}
+	p.closeUnmatchedBlocks()
+	d := p.addChild(nodeDiv)
+	d.info = strings.TrimSpace(m[1] + " " + m[2])
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return consumed
+}
+
+// hasOpenFence reports whether a div or fenced code block is open inside n.
+func hasOpenFence(n *node) bool {
+	for c := n.lastChild(); c != nil && c.open; c = c.lastChild() {
+		if c.kind == nodeDiv || c.kind == nodeCode && c.fenced {
+			return true
+		}
+	}
+	return false
+}
+
+func startSetextHeading(p *blockParser, container *node) int {
+	if p.indented || container.kind != nodeParagraph || !setextRegex.MatchString(p.line[p.nextNonspace:]) {
+		return noMatch
+	}
+	p.closeUnmatchedBlocks()
+	text := p.parseReferences(container.content.String())
+	if strings.TrimSpace(text) == "" {
+		container.content.Reset()
+		return noMatch
+	}
+	h := &node{kind: nodeHeading, parent: container.parent, open: true, line: container.line, level: 2}
+	if p.peek(p.nextNonspace) == '=' {
+		h.level = 1
+	}
+	h.content.WriteString(text)
+	siblings := container.parent.children
+	siblings[len(siblings)-1] = h
+	p.tip = h
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return consumed
+}
+
+// startTable turns the last line of a paragraph into a table header when it
+// is followed by a delimiter row with the same number of cells. Earlier lines
+// stay a paragraph.
+func startTable(p *blockParser, container *node) int {
+	rest := p.line[p.nextNonspace:]
+	if p.indented || container.kind != nodeParagraph || !strings.Contains(rest, "|") {
+		return noMatch
+	}
+	align, ok := parseTableDelimiter(rest)
+	if !ok {
+		return noMatch
+	}
+	lines := strings.Split(strings.TrimSuffix(container.content.String(), "\n"), "\n")
+	header := splitTableRow(lines[len(lines)-1])
+	if len(header) != len(align) {
+		return noMatch
+	}
+
+	p.closeUnmatchedBlocks()
+	container.content.Reset()
+	for _, line := range lines[:len(lines)-1] {
+		container.content.WriteString(line + "\n")
+	}
+	p.finalize(container)
+	t := p.addChild(nodeTable)
+	t.align, t.header = align, header
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return consumed
+}
+
+// parseTableDelimiter reads a row like "| :--- | :---: | ---: |".
+func parseTableDelimiter(line string) ([]string, bool) {
+	var align []string
+	for _, cell := range splitTableRow(line) {
+		if !tableDelimiterRegex.MatchString(cell) {
+			return nil, false
+		}
+		left, right := cell[0] == ':', cell[len(cell)-1] == ':'
+		switch {
+		case left && right:
+			align = append(align, "center")
+		case left:
+			align = append(align, "left")
+		case right:
+			align = append(align, "right")
+		default:
+			align = append(align, "")
+		}
+	}
+	return align, true
+}
+
+// splitTableRow splits a row on unescaped pipes. Leading and trailing pipes
+// are optional and \| stands for a literal pipe, even inside code spans.
+func splitTableRow(line string) []string {
+	line = strings.TrimPrefix(strings.TrimSpace(line), "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, line[start:i])
+			start = i + 1
+		}
+	}
+	cells = append(cells, line[start:])
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(strings.ReplaceAll(cell, "\\|", "|"))
+	}
+	return cells
+}
+
+func startThematicBreak(p *blockParser, _ *node) int {
+	if p.indented || !thematicBreakRegex.MatchString(p.line[p.nextNonspace:]) {
+		return noMatch
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(nodeBreak)
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return consumed
+}
+
+func startListItem(p *blockParser, container *node) int {
+	if p.indented && container.kind != nodeList {
+		return noMatch
+	}
+	data, ok := p.parseListMarker(container)
+	if !ok {
+		return noMatch
+	}
+	p.closeUnmatchedBlocks()
+	if p.tip.kind != nodeList || !listsMatch(p.tip.list, data) {
+		p.addChild(nodeList).list = data
+	}
+	p.addChild(nodeItem).list = data
+	return matched
+}
+
+func startIndentedCode(p *blockParser, _ *node) int {
+	if !p.indented || p.tip.kind == nodeParagraph || p.blank {
+		return noMatch
+	}
+	p.advanceOffset(4, true)
+	p.closeUnmatchedBlocks()
+	p.addChild(nodeCode)
+	return consumed
+}
+
+// parseListMarker reads a bullet or ordered list marker and the spaces after
+// it, which decide how far continuation lines must be indented.
+func (p *blockParser) parseListMarker(container *node) (listData, bool) {
+	if p.indent >= 4 {
+		return listData{}, false
+	}
+	rest := p.line[p.nextNonspace:]
+	data := listData{markerOffset: p.indent}
+	var markerLen int
+	if m := bulletMarkerRegex.FindString(rest); m != "" {
+		data.bullet = m[0]
+		markerLen = 1
+	} else if m := orderedMarkerRegex.FindStringSubmatch(rest); m != nil {
+		data.ordered = true
+		data.start, _ = strconv.Atoi(m[1])
+		data.bullet = m[2][0]
+		markerLen = len(m[0])
+		// Only a list starting at 1 can interrupt a paragraph
+		if container.kind == nodeParagraph && data.start != 1 {
+			return listData{}, false
+		}
+	} else {
+		return listData{}, false
+	}
+
+	next := p.peek(p.nextNonspace + markerLen)
+	if next != 0 && next != ' ' && next != '\t' {
+		return listData{}, false
+	}
+	// An empty item can't interrupt a paragraph
+	if container.kind == nodeParagraph && strings.TrimSpace(rest[markerLen:]) == "" {
+		return listData{}, false
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(markerLen, true)
+	startColumn, startOffset := p.column, p.offset
+	for {
+		p.advanceOffset(1, true)
+		next = p.peek(p.offset)
+		if p.column-startColumn >= 5 || next != ' ' && next != '\t' {
+			break
+		}
+	}
+	blankItem := p.offset >= len(p.line)
+	spaces := p.column - startColumn
+	if spaces >= 5 || spaces < 1 || blankItem {
+		// Content starts one space after the marker; the rest is indentation
+		data.padding = markerLen + 1
+		p.column, p.offset = startColumn, startOffset
+		if c := p.peek(p.offset); c == ' ' || c == '\t' {
+			p.advanceOffset(1, true)
+		}
+	} else {
+		data.padding = markerLen + spaces
+	}
+	return data, true
+}
+
+func listsMatch(a, b listData) bool {
+	return a.ordered == b.ordered && a.bullet == b.bullet
+}
+
+// finalize closes a block and makes its parent the tip.
+func (p *blockParser) finalize(n *node) {
+	n.open = false
+	switch n.kind {
+	case nodeParagraph:
+		text := p.parseReferences(n.content.String())
+		n.content.Reset()
+		if strings.TrimSpace(text) == "" {
+			n.remove()
+		} else {
+			n.content.WriteString(text)
+		}
+	case nodeCode:
+		content := n.content.String()
+		if n.fenced {
+			first, rest, _ := strings.Cut(content, "\n")
+			n.info = unescapeString(strings.TrimSpace(first))
+			n.literal = rest
+		} else {
+			n.literal = trailingBlankRegex.ReplaceAllString(content, "\n")
+		}
+	case nodeTable:
+		for _, line := range strings.Split(n.content.String(), "\n") {
+			if line != "" {
+				n.rows = append(n.rows, splitTableRow(line))
+			}
+		}
+	case nodeList:
+		n.tight = true
+	items:
+		for i, item := range n.children {
+			last := i == len(n.children)-1
+			if endsWithBlankLine(item) && !last {
+				n.tight = false
+				break
+			}
+			for j, sub := range item.children {
+				if endsWithBlankLine(sub) && (!last || j < len(item.children)-1) {
+					n.tight = false
+					break items
+				}
+			}
+		}
+	}
+	p.tip = n.parent
+}
+
+func endsWithBlankLine(n *node) bool {
+	for n != nil {
+		if n.lastLineBlank {
+			return true
+		}
+		if n.lastLineChecked || n.kind != nodeList && n.kind != nodeItem {
+			n.lastLineChecked = true
+			return false
+		}
+		n.lastLineChecked = true
+		n = n.lastChild()
+	}
+	return false
+}
+
+// parseReferences strips link reference definitions from the start of a
+// paragraph, records them and returns the remaining text.
+func (p *blockParser) parseReferences(text string) string {
+	for len(text) > 0 && text[0] == '[' {
+		n := p.parseReference(text)
+		if n == 0 {
+			break
+		}
+		text = text[n:]
+	}
+	return text
+}
+
+// parseReference parses one definition at the start of s and returns how
+// many bytes it used, or 0 if s doesn't start with one.
+func (p *blockParser) parseReference(s string) int {
+	n := parseLinkLabel(s)
+	if n <= 2 || n >= len(s) || s[n] != ':' {
+		return 0
+	}
+	label := s[1 : n-1]
+	pos := skipSpaceNewline(s, n+1)
+	dest, end, ok := parseLinkDestination(s, pos)
+	if !ok || end == pos {
+		return 0
+	}
+	pos = end
+	beforeTitle := pos
+	pos = skipSpaceNewline(s, pos)
+	title, end, ok := "", pos, false
+	if pos != beforeTitle {
+		title, end, ok = parseLinkTitle(s, pos)
+	}
+	if ok {
+		pos = end
+	} else {
+		title, pos = "", beforeTitle
+	}
+
+	// Nothing but spaces may follow on the line
+	atLineEnd := func(i int) (int, bool) {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i == len(s) {
+			return i, true
+		}
+		if s[i] == '\n' {
+			return i + 1, true
+		}
+		return i, false
+	}
+	end, atEnd := atLineEnd(pos)
+	if !atEnd {
+		if title == "" {
+			return 0
+		}
+		// The title wasn't alone on its line; retry without it
+		title = ""
+		if end, atEnd = atLineEnd(beforeTitle); !atEnd {
+			return 0
+		}
+	}
+
+	key := normalizeLabel(label)
+	if key == "" {
+		return 0
+	}
+	if _, exists := p.refs[key]; !exists {
+		p.refs[key] = linkRef{dest: dest, title: title}
+	}
+	return end
+}
+
+// normalizeLabel case-folds a link label and collapses its whitespace, so
+// references match regardless of how they are written.
+func normalizeLabel(label string) string {
+	return strings.ToUpper(strings.ToLower(strings.Join(strings.Fields(label), " ")))
+}
+
+// convert turns the finished block tree into AST nodes, parsing inlines.
+func (p *blockParser) convert(nodes []*node) []Block {
+	var blocks []Block
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeParagraph:
+			text := strings.TrimSpace(n.content.String())
+			if text == ". . ." {
+				blocks = append(blocks, &Pause{})
+				continue
+			}
+			blocks = append(blocks, &Paragraph{Inlines: parseInlines(text, p.refs)})
+		case nodeHeading:
+			blocks = append(blocks, &Heading{Level: n.level, Inlines: parseInlines(strings.TrimSpace(n.content.String()), p.refs)})
+		case nodeCode:
+			lang, steps := parseCodeInfo(n.info, strings.Count(n.literal, "\n"))
+			blocks = append(blocks, &CodeBlock{Info: n.info, Lang: lang, Code: n.literal, Steps: steps})
+		case nodeBreak:
+			blocks = append(blocks, &ThematicBreak{})
+		case nodeBlockQuote:
+			blocks = append(blocks, &BlockQuote{Blocks: p.convert(n.children)})
+		case nodeList:
+			list := &List{Ordered: n.list.ordered, Start: n.list.start, Tight: n.tight, Incremental: n.list.bullet == '+'}
+			for _, item := range n.children {
+				list.Items = append(list.Items, &ListItem{Blocks: p.convert(item.children)})
+			}
+			blocks = append(blocks, list)
+		case nodeTable:
+			cells := func(row []string) []*TableCell {
+				out := make([]*TableCell, len(n.align))
+				for i := range out {
+					out[i] = &TableCell{}
+					if i < len(row) {
+						out[i].Inlines = parseInlines(row[i], p.refs)
+					}
+				}
+				return out
+			}
+			table := &Table{Align: n.align, Header: cells(n.header)}
+			for _, row := range n.rows {
+				table.Rows = append(table.Rows, cells(row))
+			}
+			blocks = append(blocks, table)
+		case nodeDiv:
+			if name, _, _ := strings.Cut(n.info, " "); name == "columns" {
+				blocks = append(blocks, p.columns(n))
+			} else {
+				// A column outside of ::: columns is just its content
+				blocks = append(blocks, p.convert(n.children)...)
+			}
+		}
+	}
+	return blocks
+}
+
+// columns converts a ::: columns container. Content between its ::: col
+// children becomes a column of its own.
+func (p *blockParser) columns(n *node) *Columns {
+	cols := &Columns{}
+	var loose []*node
+	flush := func() {
+		if len(loose) > 0 {
+			cols.Columns = append(cols.Columns, &Column{Width: "1fr", Blocks: p.convert(loose)})
+			loose = nil
+		}
+	}
+	for _, c := range n.children {
+		name, width, _ := strings.Cut(c.info, " ")
+		if c.kind != nodeDiv || name == "columns" {
+			loose = append(loose, c)
+			continue
+		}
+		flush()
+		switch {
+		case width == "":
+			width = "1fr"
+		case !strings.HasSuffix(width, "fr") && !strings.HasSuffix(width, "%"):
+			width += "fr"
+		}
+		cols.Columns = append(cols.Columns, &Column{Width: width, Blocks: p.convert(c.children)})
+	}
+	flush()
+	return cols
+}
+
+// parseCodeInfo splits a fenced code info string into the language and the
+// line highlight steps of a trailing attribute: {3,5-7} highlights lines 3
+// and 5 to 7, and {1|3-4|6} steps through three sets. Ranges are clamped to
+// the block's line count and a malformed attribute is ignored.
+func parseCodeInfo(info string, lines int) (string, [][]int) {
+	lang, attr, hasAttr := strings.Cut(info, "{")
+	lang, _, _ = strings.Cut(strings.TrimSpace(lang), " ")
+	if !hasAttr {
+		return lang, nil
+	}
+	attr, _, closed := strings.Cut(attr, "}")
+	if !closed {
+		return lang, nil
+	}
+	var steps [][]int
+	for _, step := range strings.Split(attr, "|") {
+		set := []int{}
+		for _, part := range strings.Split(step, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			from, to, isRange := strings.Cut(part, "-")
+			start, err := strconv.Atoi(strings.TrimSpace(from))
+			end := start
+			if err == nil && isRange {
+				end, err = strconv.Atoi(strings.TrimSpace(to))
+			}
+			if err != nil || start < 1 || end < start {
+				return lang, nil
+			}
+			for n := start; n <= end && n <= lines; n++ {
+				set = append(set, n)
+			}
+		}
+		steps = append(steps, set)
+	}
+	return lang, steps
+}
@@ -0,0 +1,213 @@
+package deck
+
+import "testing"
+
+// specExamples are examples from the CommonMark spec (0.31), markdown in and
+// HTML out. Raw HTML, the + list marker, which reveals items step by step,
+// and percent-encoding of link destinations are left out as the deck
+// deliberately differs from the spec there.
+var specExamples = []struct {
+	section  string
+	markdown string
+	html     string
+}{
+	// Tabs and backslash escapes
+	{"tabs", "\tfoo\tbaz\t\tbim\n", "<pre><code>foo\tbaz\t\tbim\n</code></pre>\n"},
+	{"escapes", "\\*not emphasized*\n\\[not a link](/foo)\n", "<p>*not emphasized*\n[not a link](/foo)</p>\n"},
+	{"escapes", "`` \\[\\` ``\n", "<p><code>\\[\\`</code></p>\n"},
+
+	// Thematic breaks and ATX headings
+	{"thematic breaks", "***\n---\n___\n", "<hr />\n<hr />\n<hr />\n"},
+	{"thematic breaks", "+++\n", "<p>+++</p>\n"},
+	{"thematic breaks", " - - -\n", "<hr />\n"},
+	{"ATX headings", "# foo\n## foo\n### foo\n#### foo\n##### foo\n###### foo\n", "<h1>foo</h1>\n<h2>foo</h2>\n<h3>foo</h3>\n<h4>foo</h4>\n<h5>foo</h5>\n<h6>foo</h6>\n"},
+	{"ATX headings", "####### foo\n", "<p>####### foo</p>\n"},
+	{"ATX headings", "#5 bolt\n\n#hashtag\n", "<p>#5 bolt</p>\n<p>#hashtag</p>\n"},
+	{"ATX headings", "# foo *bar* \\*baz\\*\n", "<h1>foo <em>bar</em> *baz*</h1>\n"},
+	{"ATX headings", "## foo ##\n  ###   bar    ###\n", "<h2>foo</h2>\n<h3>bar</h3>\n"},
+
+	// Setext headings
+	{"setext headings", "Foo *bar*\n=========\n\nFoo *bar*\n---------\n", "<h1>Foo <em>bar</em></h1>\n<h2>Foo <em>bar</em></h2>\n"},
+	{"setext headings", "Foo *bar\nbaz*\n====\n", "<h1>Foo <em>bar\nbaz</em></h1>\n"},
+	{"setext headings", "Foo\n-------------------------\n\nFoo\n=\n", "<h2>Foo</h2>\n<h1>Foo</h1>\n"},
+	{"setext headings", "   Foo\n---\n\n  Foo\n-----\n\n  Foo\n  ===\n", "<h2>Foo</h2>\n<h2>Foo</h2>\n<h1>Foo</h1>\n"},
+	{"setext headings", "    Foo\n    ---\n\n    Foo\n---\n", "<pre><code>Foo\n---\n\nFoo\n</code></pre>\n<hr />\n"},
+	{"setext headings", "Foo\n= =\n\nFoo\n--- -\n", "<p>Foo\n= =</p>\n<p>Foo</p>\n<hr />\n"},
+	{"setext headings", "> Foo\n---\n", "<blockquote>\n<p>Foo</p>\n</blockquote>\n<hr />\n"},
+	{"setext headings", "- Foo\n---\n", "<ul>\n<li>Foo</li>\n</ul>\n<hr />\n"},
+	{"setext headings", "Foo\nBar\n---\n", "<h2>Foo\nBar</h2>\n"},
+	{"setext headings", "\n====\n", "<p>====</p>\n"},
+
+	// Indented code blocks
+	{"indented code", "    a simple\n      indented code block\n", "<pre><code>a simple\n  indented code block\n</code></pre>\n"},
+	{"indented code", "  - foo\n\n    bar\n", "<ul>\n<li>\n<p>foo</p>\n<p>bar</p>\n</li>\n</ul>\n"},
+	{"indented code", "    <a/>\n    *hi*\n\n    - one\n", "<pre><code>&lt;a/&gt;\n*hi*\n\n- one\n</code></pre>\n"},
+	{"indented code", "    chunk1\n\n    chunk2\n  \n \n \n    chunk3\n", "<pre><code>chunk1\n\nchunk2\n\n\n\nchunk3\n</code></pre>\n"},
+	{"indented code", "Foo\n    bar\n", "<p>Foo\nbar</p>\n"},
+	{"indented code", "    foo\nbar\n", "<pre><code>foo\n</code></pre>\n<p>bar</p>\n"},
+
+	// Fenced code blocks
+	{"fenced code", "```\n<\n >\n```\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+	{"fenced code", "~~~\n<\n >\n~~~\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+	{"fenced code", "```\naaa\n~~~\n```\n", "<pre><code>aaa\n~~~\n</code></pre>\n"},
+	{"fenced code", "~~~\naaa\n```\n~~~\n", "<pre><code>aaa\n```\n</code></pre>\n"},
+	{"fenced code", "````\naaa\n```\n``````\n", "<pre><code>aaa\n```\n</code></pre>\n"},
+	{"fenced code", "```\n", "<pre><code></code></pre>\n"},
+	{"fenced code", "`````\n\n```\naaa\n", "<pre><code>\n```\naaa\n</code></pre>\n"},
+	{"fenced code", "> ```\n> aaa\n\nbbb\n", "<blockquote>\n<pre><code>aaa\n</code></pre>\n</blockquote>\n<p>bbb</p>\n"},
+	{"fenced code", " ```\n aaa\naaa\n```\n", "<pre><code>aaa\naaa\n</code></pre>\n"},
+	{"fenced code", "   ```\n   aaa\n    aaa\n  aaa\n   ```\n", "<pre><code>aaa\n aaa\naaa\n</code></pre>\n"},
+	{"fenced code", "    ```\n    aaa\n    ```\n", "<pre><code>```\naaa\n```\n</code></pre>\n"},
+	{"fenced code", "``` ```\naaa\n", "<p><code> </code>\naaa</p>\n"},
+	{"fenced code", "foo\n```\nbar\n```\nbaz\n", "<p>foo</p>\n<pre><code>bar\n</code></pre>\n<p>baz</p>\n"},
+	{"fenced code", "```;\n```\n", "<pre><code class=\"language-;\"></code></pre>\n"},
+	{"fenced code", "~~~ aa ``` ~~~\nfoo\n~~~\n", "<pre><code class=\"language-aa\">foo\n</code></pre>\n"},
+
+	// Paragraphs and blank lines
+	{"paragraphs", "aaa\n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+	{"paragraphs", "  aaa\n bbb\n", "<p>aaa\nbbb</p>\n"},
+	{"paragraphs", "aaa\n             bbb\n                                       ccc\n", "<p>aaa\nbbb\nccc</p>\n"},
+	{"paragraphs", "aaa     \nbbb     \n", "<p>aaa<br />\nbbb</p>\n"},
+	{"blank lines", "  \n\naaa\n  \n\n# aaa\n\n  \n", "<p>aaa</p>\n<h1>aaa</h1>\n"},
+
+	// Block quotes
+	{"block quotes", "> # Foo\n> bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+	{"block quotes", "># Foo\n>bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+	{"block quotes", "> # Foo\n> bar\nbaz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+	{"block quotes", "> foo\n---\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<hr />\n"},
+	{"block quotes", "> - foo\n- bar\n", "<blockquote>\n<ul>\n<li>foo</li>\n</ul>\n</blockquote>\n<ul>\n<li>bar</li>\n</ul>\n"},
+	{"block quotes", ">\n", "<blockquote>\n</blockquote>\n"},
+	{"block quotes", "> foo\n\n> bar\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+	{"block quotes", "> foo\n>\n> bar\n", "<blockquote>\n<p>foo</p>\n<p>bar</p>\n</blockquote>\n"},
+	{"block quotes", "> > > foo\nbar\n", "<blockquote>\n<blockquote>\n<blockquote>\n<p>foo\nbar</p>\n</blockquote>\n</blockquote>\n</blockquote>\n"},
+	{"block quotes", ">     code\n\n>    not code\n", "<blockquote>\n<pre><code>code\n</code></pre>\n</blockquote>\n<blockquote>\n<p>not code</p>\n</blockquote>\n"},
+
+	// List items and lists
+	{"list items", "1.  A paragraph\n    with two lines.\n\n        indented code\n\n    > A block quote.\n", "<ol>\n<li>\n<p>A paragraph\nwith two lines.</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>A block quote.</p>\n</blockquote>\n</li>\n</ol>\n"},
+	{"list items", "- one\n\n two\n", "<ul>\n<li>one</li>\n</ul>\n<p>two</p>\n"},
+	{"list items", "- one\n\n  two\n", "<ul>\n<li>\n<p>one</p>\n<p>two</p>\n</li>\n</ul>\n"},
+	{"list items", "-one\n\n2.two\n", "<p>-one</p>\n<p>2.two</p>\n"},
+	{"list items", "123456789. ok\n", "<ol start=\"123456789\">\n<li>ok</li>\n</ol>\n"},
+	{"list items", "1234567890. not ok\n", "<p>1234567890. not ok</p>\n"},
+	{"list items", "0. ok\n", "<ol start=\"0\">\n<li>ok</li>\n</ol>\n"},
+	{"list items", "-1. not ok\n", "<p>-1. not ok</p>\n"},
+	{"list items", "- foo\n\n      bar\n", "<ul>\n<li>\n<p>foo</p>\n<pre><code>bar\n</code></pre>\n</li>\n</ul>\n"},
+	{"list items", "-\n  foo\n-\n  ```\n  bar\n  ```\n-\n      baz\n", "<ul>\n<li>foo</li>\n<li>\n<pre><code>bar\n</code></pre>\n</li>\n<li>\n<pre><code>baz\n</code></pre>\n</li>\n</ul>\n"},
+	{"list items", "- foo\n-\n- bar\n", "<ul>\n<li>foo</li>\n<li></li>\n<li>bar</li>\n</ul>\n"},
+	{"nested lists", "- foo\n  - bar\n    - baz\n      - boo\n", "<ul>\n<li>foo\n<ul>\n<li>bar\n<ul>\n<li>baz\n<ul>\n<li>boo</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n</li>\n</ul>\n"},
+	{"nested lists", "- foo\n - bar\n  - baz\n   - boo\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n<li>baz</li>\n<li>boo</li>\n</ul>\n"},
+	{"nested lists", "10) foo\n    - bar\n", "<ol start=\"10\">\n<li>foo\n<ul>\n<li>bar</li>\n</ul>\n</li>\n</ol>\n"},
+	{"nested lists", "- - foo\n", "<ul>\n<li>\n<ul>\n<li>foo</li>\n</ul>\n</li>\n</ul>\n"},
+	{"nested lists", "1. - 2. foo\n", "<ol>\n<li>\n<ul>\n<li>\n<ol start=\"2\">\n<li>foo</li>\n</ol>\n</li>\n</ul>\n</li>\n</ol>\n"},
+	{"nested lists", "- # Foo\n- Bar\n  ---\n  baz\n", "<ul>\n<li>\n<h1>Foo</h1>\n</li>\n<li>\n<h2>Bar</h2>\nbaz</li>\n</ul>\n"},
+	{"lists", "1. foo\n2. bar\n3) baz\n", "<ol>\n<li>foo</li>\n<li>bar</li>\n</ol>\n<ol start=\"3\">\n<li>baz</li>\n</ol>\n"},
+	{"lists", "Foo\n- bar\n- baz\n", "<p>Foo</p>\n<ul>\n<li>bar</li>\n<li>baz</li>\n</ul>\n"},
+	{"lists", "The number of windows in my house is\n14.  The number of doors is 6.\n", "<p>The number of windows in my house is\n14.  The number of doors is 6.</p>\n"},
+	{"lists", "- a\n- b\n\n- c\n", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n<li>\n<p>c</p>\n</li>\n</ul>\n"},
+	{"lists", "- a\n  - b\n\n    c\n- d\n", "<ul>\n<li>a\n<ul>\n<li>\n<p>b</p>\n<p>c</p>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+	{"lists", "* a\n> b\n>\n* c\n", "<ul>\n<li>a</li>\n</ul>\n<blockquote>\n<p>b</p>\n</blockquote>\n<ul>\n<li>c</li>\n</ul>\n"},
+	{"lists", "- a\n  > b\n  ```\n  c\n  ```\n- d\n", "<ul>\n<li>a\n<blockquote>\n<p>b</p>\n</blockquote>\n<pre><code>c\n</code></pre>\n</li>\n<li>d</li>\n</ul>\n"},
+
+	// Backslash escapes, entities and code spans
+	{"entities", "&nbsp; &amp; &copy; &AElig; &Dcaron;\n&frac34; &HilbertSpace; &DifferentialD;\n&ClockwiseContourIntegral; &ngE;\n", "<p>  &amp; © Æ Ď\n¾ ℋ ⅆ\n∲ ≧̸</p>\n"},
+	{"entities", "&#35; &#1234; &#992; &#0;\n", "<p># Ӓ Ϡ �</p>\n"},
+	{"entities", "&#X22; &#XD06; &#xcab;\n", "<p>&quot; ആ ಫ</p>\n"},
+	{"entities", "&nbsp &x; &#; &#x;\n&#87654321;\n&#abcdef0;\n&ThisIsNotDefined; &hi?;\n", "<p>&amp;nbsp &amp;x; &amp;#; &amp;#x;\n&amp;#87654321;\n&amp;#abcdef0;\n&amp;ThisIsNotDefined; &amp;hi?;</p>\n"},
+	{"entities", "&copy\n", "<p>&amp;copy</p>\n"},
+	{"entities", "[foo](/f&ouml;&ouml; \"f&ouml;&ouml;\")\n", "<p><a href=\"/föö\" title=\"föö\">foo</a></p>\n"},
+	{"entities", "`f&ouml;&ouml;`\n", "<p><code>f&amp;ouml;&amp;ouml;</code></p>\n"},
+	{"entities", "    f&ouml;f&ouml;\n", "<pre><code>f&amp;ouml;f&amp;ouml;\n</code></pre>\n"},
+	{"entities", "&#42;foo&#42;\n*foo*\n", "<p>*foo*\n<em>foo</em></p>\n"},
+	{"entities", "&#42; foo\n\n* foo\n", "<p>* foo</p>\n<ul>\n<li>foo</li>\n</ul>\n"},
+	{"code spans", "`` foo ` bar ``\n", "<p><code>foo ` bar</code></p>\n"},
+	{"code spans", "` `` `\n", "<p><code>``</code></p>\n"},
+	{"code spans", "`foo   bar \nbaz`\n", "<p><code>foo   bar  baz</code></p>\n"},
+	{"code spans", "*foo`*`\n", "<p>*foo<code>*</code></p>\n"},
+	{"code spans", "```foo``\n", "<p>```foo``</p>\n"},
+
+	// Emphasis and strong emphasis
+	{"emphasis", "*foo bar*\n", "<p><em>foo bar</em></p>\n"},
+	{"emphasis", "a * foo bar*\n", "<p>a * foo bar*</p>\n"},
+	{"emphasis", "a*\"foo\"*\n", "<p>a*&quot;foo&quot;*</p>\n"},
+	{"emphasis", "foo*bar*\n", "<p>foo<em>bar</em></p>\n"},
+	{"emphasis", "_foo bar_\n", "<p><em>foo bar</em></p>\n"},
+	{"emphasis", "foo_bar_\n", "<p>foo_bar_</p>\n"},
+	{"emphasis", "_foo_bar_baz_\n", "<p><em>foo_bar_baz</em></p>\n"},
+	{"emphasis", "*foo*bar\n", "<p><em>foo</em>bar</p>\n"},
+	{"emphasis", "**foo bar**\n", "<p><strong>foo bar</strong></p>\n"},
+	{"emphasis", "** foo bar**\n", "<p>** foo bar**</p>\n"},
+	{"emphasis", "foo**bar**\n", "<p>foo<strong>bar</strong></p>\n"},
+	{"emphasis", "__foo bar__\n", "<p><strong>foo bar</strong></p>\n"},
+	{"emphasis", "foo__bar__\n", "<p>foo__bar__</p>\n"},
+	{"emphasis", "*foo [bar](/url)*\n", "<p><em>foo <a href=\"/url\">bar</a></em></p>\n"},
+	{"emphasis", "*foo\nbar*\n", "<p><em>foo\nbar</em></p>\n"},
+	{"emphasis", "_foo __bar__ baz_\n", "<p><em>foo <strong>bar</strong> baz</em></p>\n"},
+	{"emphasis", "*foo**bar**baz*\n", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+	{"emphasis", "*foo**bar*\n", "<p><em>foo**bar</em></p>\n"},
+	{"emphasis", "***foo** bar*\n", "<p><em><strong>foo</strong> bar</em></p>\n"},
+	{"emphasis", "foo***bar***baz\n", "<p>foo<em><strong>bar</strong></em>baz</p>\n"},
+	{"emphasis", "foo******bar*********baz\n", "<p>foo<strong><strong><strong>bar</strong></strong></strong>***baz</p>\n"},
+	{"emphasis", "**foo*\n", "<p>*<em>foo</em></p>\n"},
+	{"emphasis", "*foo**\n", "<p><em>foo</em>*</p>\n"},
+	{"emphasis", "***foo***\n", "<p><em><strong>foo</strong></em></p>\n"},
+	{"emphasis", "_____foo_____\n", "<p><em><strong><strong>foo</strong></strong></em></p>\n"},
+	{"emphasis", "*foo _bar* baz_\n", "<p><em>foo _bar</em> baz_</p>\n"},
+	{"emphasis", "**foo**bar\n", "<p><strong>foo</strong>bar</p>\n"},
+	{"emphasis", "*[bar*](/url)\n", "<p>*<a href=\"/url\">bar*</a></p>\n"},
+	{"emphasis", "*a `*`*\n", "<p><em>a <code>*</code></em></p>\n"},
+	{"emphasis", "foo *\\**\n", "<p>foo <em>*</em></p>\n"},
+
+	// Links and link reference definitions
+	{"links", "[link](/uri \"title\")\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+	{"links", "[link]()\n", "<p><a href=\"\">link</a></p>\n"},
+	{"links", "[link](<>)\n", "<p><a href=\"\">link</a></p>\n"},
+	{"links", "[link](/my uri)\n", "<p>[link](/my uri)</p>\n"},
+	{"links", "[link](<foo\\>)\n", "<p>[link](&lt;foo&gt;)</p>\n"},
+	{"links", "[link](foo(and(bar)))\n", "<p><a href=\"foo(and(bar))\">link</a></p>\n"},
+	{"links", "[link](#fragment)\n\n[link](https://example.com#fragment)\n", "<p><a href=\"#fragment\">link</a></p>\n<p><a href=\"https://example.com#fragment\">link</a></p>\n"},
+	{"links", "[link [foo [bar]]](/uri)\n", "<p><a href=\"/uri\">link [foo [bar]]</a></p>\n"},
+	{"links", "[link *foo **bar** `#`*](/uri)\n", "<p><a href=\"/uri\">link <em>foo <strong>bar</strong> <code>#</code></em></a></p>\n"},
+	{"links", "[foo [bar](/uri)](/uri)\n", "<p>[foo <a href=\"/uri\">bar</a>](/uri)</p>\n"},
+	{"links", "[foo *bar](baz*)\n", "<p><a href=\"baz*\">foo *bar</a></p>\n"},
+	{"links", "<https://foo.bar.baz/test?q=hello&id=22&boolean>\n", "<p><a href=\"https://foo.bar.baz/test?q=hello&amp;id=22&amp;boolean\">https://foo.bar.baz/test?q=hello&amp;id=22&amp;boolean</a></p>\n"},
+	{"link references", "[foo]: /url \"title\"\n\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"link references", "   [foo]: \n      /url  \n           'the title'  \n\n[foo]\n", "<p><a href=\"/url\" title=\"the title\">foo</a></p>\n"},
+	{"link references", "[foo]:\n/url\n\n[foo]\n", "<p><a href=\"/url\">foo</a></p>\n"},
+	{"link references", "[foo]:\n\n[foo]\n", "<p>[foo]:</p>\n<p>[foo]</p>\n"},
+	{"link references", "[foo]: /url\\*baz \"foo\\\"bar\\baz\"\n\n[foo]\n", "<p><a href=\"/url*baz\" title=\"foo&quot;bar\\baz\">foo</a></p>\n"},
+	{"link references", "[foo]: /url '\ntitle\nline1\n'\n\n[foo]\n", "<p><a href=\"/url\" title=\"\ntitle\nline1\n\">foo</a></p>\n"},
+	{"link references", "[foo]\n\n[foo]: url\n", "<p><a href=\"url\">foo</a></p>\n"},
+	{"link references", "[foo]\n\n[foo]: first\n[foo]: second\n", "<p><a href=\"first\">foo</a></p>\n"},
+	{"link references", "[FOO]: /url\n\n[Foo]\n", "<p><a href=\"/url\">Foo</a></p>\n"},
+	{"link references", "[foo]: /url\n", ""},
+	{"link references", "[foo]: /url \"title\" ok\n", "<p>[foo]: /url &quot;title&quot; ok</p>\n"},
+	{"link references", "    [foo]: /url \"title\"\n\n[foo]\n", "<pre><code>[foo]: /url &quot;title&quot;\n</code></pre>\n<p>[foo]</p>\n"},
+	{"link references", "Foo\n[bar]: /baz\n\n[bar]\n", "<p>Foo\n[bar]: /baz</p>\n<p>[bar]</p>\n"},
+	{"link references", "# [Foo]\n[foo]: /url\n> bar\n", "<h1><a href=\"/url\">Foo</a></h1>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+	{"link references", "[foo][bar]\n\n[bar]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"link references", "[foo][]\n\n[foo]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+	{"link references", "[foo] [bar]\n\n[bar]: /url \"title\"\n", "<p>[foo] <a href=\"/url\" title=\"title\">bar</a></p>\n"},
+	{"link references", "[foo][bar][baz]\n\n[baz]: /url1\n[bar]: /url2\n", "<p><a href=\"/url2\">foo</a><a href=\"/url1\">baz</a></p>\n"},
+	{"link references", "> [foo]: /url\n\n[foo]\n", "<blockquote>\n</blockquote>\n<p><a href=\"/url\">foo</a></p>\n"},
+
+	// Images
+	{"images", "![foo](/url \"title\")\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\" /></p>\n"},
+	{"images", "![foo *bar*]\n\n[foo *bar*]: train.jpg \"train & tracks\"\n", "<p><img src=\"train.jpg\" alt=\"foo bar\" title=\"train &amp; tracks\" /></p>\n"},
+	{"images", "![foo ![bar](/url)](/url2)\n", "<p><img src=\"/url2\" alt=\"foo bar\" /></p>\n"},
+
+	// Hard and soft line breaks
+	{"line breaks", "foo  \nbaz\n", "<p>foo<br />\nbaz</p>\n"},
+	{"line breaks", "foo\\\nbaz\n", "<p>foo<br />\nbaz</p>\n"},
+	{"line breaks", "foo\nbaz\n", "<p>foo\nbaz</p>\n"},
+	{"line breaks", "foo\\\n", "<p>foo\\</p>\n"},
+	{"line breaks", "### foo  \n", "<h3>foo</h3>\n"},
+}
+
+func TestCommonMarkSpec(t *testing.T) {
+	for _, ex := range specExamples {
+		// An empty asset base leaves relative link targets as the spec has them
+		if got := RenderHTML(ParseBlocks(ex.markdown), ""); got != ex.html {
+			t.Errorf("%s: %q\n got %q\nwant %q", ex.section, ex.markdown, got, ex.html)
+		}
+	}
+}
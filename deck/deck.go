@@ -0,0 +1,347 @@
+// Package deck parses a slides.md presentation: the frontmatter, includes
+// and code imported from files, the split into slides and sub-slides,
+// directives and speaker notes, and the CommonMark content of every slide,
+// which it renders to HTML. The syntax trees are kept for exporters that lay
+// slides out themselves.
+package deck
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"slides/theme"
+)
+
+var (
+	noteTrailerRegex  = regexp.MustCompile(`^Notes?:`)
+	notesCommentRegex = regexp.MustCompile(`^<!--\s*notes\s*-->$`)
+)
+
+// DefaultAssetBase is where relative asset paths point unless
+// Options.AssetBase says otherwise.
+const DefaultAssetBase = "/assets/"
+
+// uriSchemeRegex matches the scheme of absolute URIs such as https: or mailto:.
+var uriSchemeRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]{0,31}:`)
+
+// safeSchemes are the URI schemes a slide may link to. Images may also be
+// data:image/ URIs.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true}
+
+// assetPath prepends base to src unless src is empty, absolute or a URI.
+// URIs with any other scheme than the safe ones, such as javascript:, are
+// replaced by "#" so a deck can't run script in the pages it is shown in.
+func assetPath(src, base string, image bool) string {
+	// Browsers ignore whitespace and control characters within a scheme
+	compact := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, src)
+	if scheme := uriSchemeRegex.FindString(compact); scheme != "" {
+		scheme = strings.ToLower(strings.TrimSuffix(scheme, ":"))
+		if safeSchemes[scheme] || (image && strings.HasPrefix(strings.ToLower(compact), "data:image/")) {
+			return src
+		}
+		return "#"
+	}
+	if src == "" || strings.HasPrefix(src, "/") || strings.HasPrefix(src, "#") {
+		return src
+	}
+	return base + src
+}
+
+// Options controls how a deck is read.
+type Options struct {
+	// Path is the markdown file. Includes and file= code blocks are
+	// resolved relative to it and errors point into it. Parse assumes
+	// slides.md in the working directory when it is empty.
+	Path string
+	// Config holds the themes to pick from. Without one the deck has no
+	// theme: no first or last slide and the cut transition.
+	Config *theme.Config
+	// Theme names the theme unless the frontmatter picks one.
+	Theme string
+	// AssetBase is prepended to relative image, link and background paths,
+	// DefaultAssetBase when empty. Static builds use a relative "assets/"
+	// so the output works from any subpath.
+	AssetBase string
+}
+
+// Slide is one slide in reading order, rendered to HTML.
+type Slide struct {
+	Content         template.HTML
+	Notes           template.HTML
+	Node            *SlideNode // parsed content and notes, for exporters that need structure
+	Title           string     // text of the slide's first heading
+	Text            string     // plain text of Content, for search
+	NotesText       string     // plain text of Notes
+	Number          int        // 1-based position in reading order
+	Section         int        // 1-based position among top-level slides
+	Sub             int        // 0 for a top-level slide, 1.. for its sub-slides
+	File            string     // markdown file the slide comes from, empty for theme slides
+	Line            int        // line in File where the slide starts
+	EndLine         int        // last line in File of the slide
+	Class           string     // from the slide's directives
+	Layout          string
+	Background      string // CSS color
+	BackgroundImage string // asset path
+}
+
+// Label is the slide's position as the counter shows it: "3" for a
+// top-level slide and "3.2" for its second sub-slide.
+func (s Slide) Label() string {
+	if s.Sub == 0 {
+		return strconv.Itoa(s.Section)
+	}
+	return fmt.Sprintf("%d.%d", s.Section, s.Sub)
+}
+
+// Deck is a parsed presentation together with the theme it is rendered with.
+type Deck struct {
+	Title       string
+	Author      string
+	Date        string
+	ThemeName   string      // Options.Theme unless the frontmatter picks one
+	Theme       theme.Theme // with the frontmatter's overrides applied
+	Transition  string
+	Split       SplitRules  // how the markdown was split into slides
+	Frontmatter Frontmatter // as written in the deck, before defaults
+	Files       []string    // absolute paths of the deck's file and every file it includes or imports code from
+	Slides      []Slide
+
+	assetBase string
+}
+
+// AssetPath resolves the image src, such as the theme's logo, the way image
+// paths in the deck's markdown are resolved.
+func (d *Deck) AssetPath(src string) string {
+	return assetPath(src, d.assetBase, true)
+}
+
+// SlideNumber finds the slide whose label, "3" or "3.2", is given, as in a
+// ?slide= link. It returns 0 when there is no such slide.
+func (d *Deck) SlideNumber(label string) int {
+	for _, slide := range d.Slides {
+		if slide.Label() == strings.TrimSpace(label) {
+			return slide.Number
+		}
+	}
+	return 0
+}
+
+// Sections is the number of top-level slides.
+func (d *Deck) Sections() int {
+	if len(d.Slides) == 0 {
+		return 0
+	}
+	return d.Slides[len(d.Slides)-1].Section
+}
+
+// Load reads the deck from the markdown file at path.
+func Load(path string, opts Options) (*Deck, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read markdown file: %w", err)
+	}
+	opts.Path = path
+	return Parse(bytes.NewReader(content), opts)
+}
+
+// Parse runs the full pipeline on the markdown read from r: pick the theme,
+// splice in includes and code from files, split the slides and convert
+// every one to HTML.
+func Parse(r io.Reader, opts Options) (*Deck, error) {
+	mdContent, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read markdown: %w", err)
+	}
+	mdPath := opts.Path
+	if mdPath == "" {
+		mdPath = "slides.md"
+	}
+	assetBase := opts.AssetBase
+	if assetBase == "" {
+		assetBase = DefaultAssetBase
+	}
+	var files []string
+	fm, lines, err := readSource(mdPath, string(mdContent), nil, &files)
+	if err != nil {
+		return nil, err
+	}
+	if lines, err = importSnippets(lines, &files); err != nil {
+		return nil, err
+	}
+
+	// Validate theme exists; the frontmatter's choice wins over the option
+	name := opts.Theme
+	if strings.TrimSpace(fm.Theme) != "" {
+		name = strings.TrimSpace(fm.Theme)
+	}
+	var base theme.Theme
+	if opts.Config != nil {
+		var exists bool
+		if base, exists = opts.Config.Themes[name]; !exists {
+			return nil, fmt.Errorf("theme '%s' not found in configuration", name)
+		}
+	}
+	theme := fm.applyTo(base)
+
+	// Determine page title: frontmatter > theme default
+	pageTitle := theme.Title
+	if strings.TrimSpace(fm.Title) != "" {
+		pageTitle = fm.Title
+	}
+
+	sections, split, err := parseMarkdown(lines, fm.SplitRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split slides: %w", err)
+	}
+	if len(sections) == 0 {
+		// An empty file still shows one blank slide
+		sections = [][]sourceText{{{File: mdPath, Line: 1, EndLine: 1}}}
+	}
+
+	// Augment slides with theme-provided first/last slides
+	if strings.TrimSpace(theme.FirstSlide) != "" {
+		sections = append([][]sourceText{{{Text: theme.FirstSlide}}}, sections...)
+	}
+	if strings.TrimSpace(theme.LastSlide) != "" {
+		sections = append(sections, []sourceText{{Text: theme.LastSlide}})
+	}
+
+	// Flatten the sections into reading order, remembering which slides
+	// start one and where they come from, and fill in placeholders
+	values := fm.values(pageTitle)
+	var slidesContent []string
+	var sources []sourceText
+	var starts []bool
+	for _, section := range sections {
+		for i, slide := range section {
+			slidesContent = append(slidesContent, expandPlaceholders(slide.Text, values))
+			sources = append(sources, slide)
+			starts = append(starts, i == 0)
+		}
+	}
+
+	// Parse every slide and render it to HTML, leaving out hidden ones. A
+	// hidden top-level slide takes its sub-slides with it.
+	doc := parseDocument(slidesContent)
+	var slides []Slide
+	section, sub, skipSection := 0, 0, false
+	for i, node := range doc.Slides {
+		if starts[i] {
+			skipSection = node.Meta.Hidden
+			if !skipSection {
+				section, sub = section+1, -1
+			}
+		}
+		if skipSection || node.Meta.Hidden {
+			continue
+		}
+		sub++
+		slides = append(slides, Slide{
+			Content:         template.HTML(RenderHTML(node.Blocks, assetBase)),
+			Notes:           template.HTML(RenderHTML(node.Notes, assetBase)),
+			Node:            node,
+			Title:           firstHeading(node.Blocks),
+			Text:            blocksText(node.Blocks),
+			NotesText:       blocksText(node.Notes),
+			Number:          len(slides) + 1,
+			Section:         section,
+			Sub:             sub,
+			File:            sources[i].File,
+			Line:            sources[i].Line,
+			EndLine:         sources[i].EndLine,
+			Class:           node.Meta.classes(),
+			Layout:          strings.TrimSpace(node.Meta.Layout),
+			Background:      node.Meta.backgroundColor(),
+			BackgroundImage: node.Meta.backgroundImage(assetBase),
+		})
+	}
+	if len(slides) == 0 && len(doc.Slides) > 0 {
+		return nil, fmt.Errorf("every slide is hidden")
+	}
+
+	// Determine transition (default cut)
+	transition := strings.ToLower(strings.TrimSpace(theme.Transition))
+	switch transition {
+	case "fade", "slide", "cut":
+	default:
+		transition = "cut"
+	}
+
+	return &Deck{
+		Title:       pageTitle,
+		Author:      strings.TrimSpace(fm.Author),
+		Date:        strings.TrimSpace(fm.Date),
+		ThemeName:   name,
+		Theme:       theme,
+		Transition:  transition,
+		Split:       split,
+		Frontmatter: fm,
+		Files:       files,
+		Slides:      slides,
+		assetBase:   assetBase,
+	}, nil
+}
+
+// splitNotes separates speaker notes from the audience-visible part of a slide.
+// Notes start at a "Note:" (or "Notes:") line, or at a <!-- notes --> comment
+// which may be closed again with <!-- /notes -->. Code blocks are skipped so
+// examples of the syntax can still be shown on a slide.
+func splitNotes(slide string) (string, string) {
+	var content, notes []string
+	var fence codeFence
+	inNotes := false
+	closable := false
+
+	for _, line := range strings.Split(slide, "\n") {
+		trimmed := strings.TrimSpace(line)
+		// Fences may be indented inside list items; lines indented further
+		// than a fence can be are indented code
+		inCode := fence.inCode(strings.TrimLeft(line, " \t"))
+		if !inCode && !strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "\t") {
+			switch {
+			case !inNotes && notesCommentRegex.MatchString(trimmed):
+				inNotes, closable = true, true
+				continue
+			case inNotes && closable && trimmed == "<!-- /notes -->":
+				inNotes, closable = false, false
+				continue
+			case !inNotes && noteTrailerRegex.MatchString(trimmed):
+				inNotes = true
+				if rest := strings.TrimSpace(noteTrailerRegex.ReplaceAllString(trimmed, "")); rest != "" {
+					notes = append(notes, rest)
+				}
+				continue
+			}
+		}
+		if inNotes {
+			notes = append(notes, line)
+		} else {
+			content = append(content, line)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(content, "\n")), strings.TrimSpace(strings.Join(notes, "\n"))
+}
+
+// parseDocument parses each slide, splitting off its directives and speaker
+// notes first.
+func parseDocument(slides []string) *Document {
+	doc := &Document{}
+	for _, slide := range slides {
+		meta, slide := parseDirectives(slide)
+		content, notes := splitNotes(slide)
+		doc.Slides = append(doc.Slides, &SlideNode{Meta: meta, Blocks: ParseBlocks(content), Notes: ParseBlocks(notes)})
+	}
+	return doc
+}
@@ -0,0 +1,78 @@
+package deck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetPaths(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string
+	}{
+		{"[a](docs/x.html)", `<a href="/assets/docs/x.html">a</a>`},
+		{"[a](/x.html)", `<a href="/x.html">a</a>`},
+		{"[a](#top)", `<a href="#top">a</a>`},
+		{"[a](https://example.com)", `<a href="https://example.com">a</a>`},
+		{"[a](HTTP://example.com)", `<a href="HTTP://example.com">a</a>`},
+		{"[a](mailto:me@example.com)", `<a href="mailto:me@example.com">a</a>`},
+		{"[a](tel:+441234)", `<a href="tel:+441234">a</a>`},
+		{"[a](javascript:alert(document.cookie))", `<a href="#">a</a>`},
+		{"[a](JavaScript:alert(1))", `<a href="#">a</a>`},
+		{"[a](<java\tscript:alert(1)>)", `<a href="#">a</a>`},
+		{"[a](javascript&colon;alert(1))", `<a href="#">a</a>`},
+		{"[a](vbscript:msgbox)", `<a href="#">a</a>`},
+		{"[a](data:text/html;base64,PHNjcmlwdD4=)", `<a href="#">a</a>`},
+		{"[a](data:image/png;base64,AAAA)", `<a href="#">a</a>`},
+		{"![i](logo.png)", `<img src="/assets/logo.png" alt="i" />`},
+		{"![i](data:image/png;base64,AAAA)", `<img src="data:image/png;base64,AAAA" alt="i" />`},
+		{"![i](data:text/html;base64,AAAA)", `<img src="#" alt="i" />`},
+		{"![i](javascript:alert(1))", `<img src="#" alt="i" />`},
+	}
+	for _, tt := range tests {
+		got := strings.TrimSpace(RenderHTML(ParseBlocks(tt.md), DefaultAssetBase))
+		if want := "<p>" + tt.want + "</p>"; got != want {
+			t.Errorf("%q:\n got %s\nwant %s", tt.md, got, want)
+		}
+	}
+}
+
+func TestBackgroundImagePath(t *testing.T) {
+	tests := []struct {
+		background string
+		want       string
+	}{
+		{"hero.jpg", "assets/hero.jpg"},
+		{"https://example.com/hero.jpg", "https://example.com/hero.jpg"},
+		{"javascript:alert(1)", "#"},
+		{"#336699", ""},
+	}
+	for _, tt := range tests {
+		if got := (SlideMeta{Background: tt.background}).backgroundImage("assets/"); got != tt.want {
+			t.Errorf("background %q: got %q, want %q", tt.background, got, tt.want)
+		}
+	}
+}
+
+func TestSplitNotes(t *testing.T) {
+	tests := []struct {
+		name          string
+		slide         string
+		content, note string
+	}{
+		{"trailer", "# A\n\nNote: say hi\nand wave", "# A", "say hi\nand wave"},
+		{"comment", "# A\n<!-- notes -->\nsecret\n<!-- /notes -->\nshown", "# A\nshown", "secret"},
+		{"backtick fence", "```\nNote: code\n```\nNote: real", "```\nNote: code\n```", "real"},
+		{"tilde fence", "~~~\nNote: code\n~~~\n\nafter", "~~~\nNote: code\n~~~\n\nafter", ""},
+		{"longer fence", "````md\n```\nNote: code\n```\n````\nNote: real", "````md\n```\nNote: code\n```\n````", "real"},
+		{"mixed fences", "~~~\n```\nNote: code\n~~~\nNote: real", "~~~\n```\nNote: code\n~~~", "real"},
+		{"indented code", "Text\n\n    Note: code\n\nmore", "Text\n\n    Note: code\n\nmore", ""},
+		{"list fence", "- item\n\n  ```\n  Note: code\n  ```", "- item\n\n  ```\n  Note: code\n  ```", ""},
+	}
+	for _, tt := range tests {
+		content, notes := splitNotes(tt.slide)
+		if content != tt.content || notes != tt.note {
+			t.Errorf("%s:\n got content %q notes %q\nwant content %q notes %q", tt.name, content, notes, tt.content, tt.note)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package deck
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Directive comments at the top of a slide set per-slide metadata. Each
+// comment holds a YAML mapping, so keys can share one comment or be spread
+// over several:
+//
+//	<!-- class: intro -->
+//	<!--
+//	layout: center
+//	background: hero.jpg
+//	-->
+
+// SlideMeta is what a slide's directives set.
+type SlideMeta struct {
+	Class      string `yaml:"class"`      // extra CSS classes for the .slide div
+	Layout     string `yaml:"layout"`     // added as a layout-NAME class
+	Background string `yaml:"background"` // CSS color or image path
+	Hidden     bool   `yaml:"hidden"`     // leave the slide out of the deck
+}
+
+// cssColorValueRegex matches the background values taken as colors rather
+// than image paths: hex, rgb()/hsl() and named colors.
+var cssColorValueRegex = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|(rgb|rgba|hsl|hsla)\([^()]*\)|[a-zA-Z]+)$`)
+
+// parseDirectives strips directive comments from the top of a slide, or from
+// just below its first line when that is a heading (as it always is when
+// slides are split at headings). The first comment that isn't a mapping of
+// known keys, such as <!-- notes -->, ends the directives and is left in
+// place.
+func parseDirectives(slide string) (SlideMeta, string) {
+	var meta SlideMeta
+	found := false
+	heading, rest := "", strings.TrimSpace(slide)
+	if strings.HasPrefix(rest, "#") {
+		heading, rest, _ = strings.Cut(rest, "\n")
+		rest = strings.TrimSpace(rest)
+	}
+	for {
+		next, after, ok := nextDirective(rest, meta)
+		if !ok {
+			break
+		}
+		meta, found, rest = next, true, after
+	}
+	if !found {
+		return meta, slide
+	}
+	if heading != "" {
+		rest = heading + "\n\n" + rest
+	}
+	return meta, rest
+}
+
+// nextDirective applies the directive comment that text starts with to meta
+// and returns the text after it. ok is false when text doesn't start with
+// one.
+func nextDirective(text string, meta SlideMeta) (next SlideMeta, after string, ok bool) {
+	if !strings.HasPrefix(text, "<!--") {
+		return meta, text, false
+	}
+	body, after, ok := strings.Cut(text[len("<!--"):], "-->")
+	if !ok {
+		return meta, text, false
+	}
+	var fields map[string]any
+	if err := yaml.Unmarshal([]byte(body), &fields); err != nil || len(fields) == 0 {
+		return meta, text, false
+	}
+	next = meta
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&next); err != nil {
+		return meta, text, false
+	}
+	return next, strings.TrimSpace(after), true
+}
+
+// onlyDirectives reports whether text holds directive comments and nothing
+// else.
+func onlyDirectives(text string) bool {
+	rest := strings.TrimSpace(text)
+	if rest == "" {
+		return false
+	}
+	for rest != "" {
+		var ok bool
+		if _, rest, ok = nextDirective(rest, SlideMeta{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// classes is the class list the .slide div gets on top of "slide".
+func (m SlideMeta) classes() string {
+	classes := strings.Fields(m.Class)
+	if layout := strings.TrimSpace(m.Layout); layout != "" {
+		classes = append(classes, "layout-"+layout)
+	}
+	if strings.TrimSpace(m.Background) != "" {
+		classes = append(classes, "with-background")
+	}
+	return strings.Join(classes, " ")
+}
+
+// backgroundColor returns the background when it is a color.
+func (m SlideMeta) backgroundColor() string {
+	if bg := strings.TrimSpace(m.Background); cssColorValueRegex.MatchString(bg) {
+		return bg
+	}
+	return ""
+}
+
+// backgroundImage returns the background's image path, resolved like any
+// other asset, when it isn't a color.
+func (m SlideMeta) backgroundImage(assetBase string) string {
+	if bg := strings.TrimSpace(m.Background); bg != "" && !cssColorValueRegex.MatchString(bg) {
+		return assetPath(bg, assetBase, true)
+	}
+	return ""
+}
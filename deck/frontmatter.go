@@ -0,0 +1,133 @@
+package deck
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"slides/theme"
+)
+
+// The frontmatter describes the deck and overrides parts of the theme:
+//
+//	---
+//	title: Quarterly Review
+//	author: Jane Doe
+//	date: 2024-05-01
+//	theme: corporate
+//	vars:
+//	  product: Widget Pro
+//	---
+//
+// Slides, including the theme's first and last slides, can refer to these
+// values as {{ .author }} or {{ .vars.product }}.
+
+// Frontmatter is the YAML block at the top of a deck. The JSON API reports
+// it as written; the split rules are reported resolved, as Deck.Split.
+type Frontmatter struct {
+	Title          string         `yaml:"title" json:"title,omitempty"`
+	Author         string         `yaml:"author" json:"author,omitempty"`
+	Date           string         `yaml:"date" json:"date,omitempty"`
+	Theme          string         `yaml:"theme" json:"theme,omitempty"`                   // overrides Options.Theme, the -theme flag
+	Transition     string         `yaml:"transition" json:"transition,omitempty"`         // overrides the theme's transition
+	Classification string         `yaml:"classification" json:"classification,omitempty"` // overrides the theme's banner label
+	Watermark      string         `yaml:"watermark" json:"watermark,omitempty"`           // turns the watermark on with this text
+	Vars           map[string]any `yaml:"vars" json:"vars,omitempty"`
+	SplitRules     `yaml:",inline" json:"-"`
+}
+
+// placeholderRegex matches {{ .name }} and {{ .vars.a.b }}.
+var placeholderRegex = regexp.MustCompile(`\{\{\s*\.([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\s*\}\}`)
+
+// ParseFrontmatter extracts YAML frontmatter delimited by --- at the top of the file.
+// Returns the frontmatter (empty if absent) and the remaining markdown body.
+func ParseFrontmatter(content string) (Frontmatter, string) {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "---\n") && trimmed != "---" {
+		return Frontmatter{}, content
+	}
+	// Find closing delimiter
+	parts := strings.SplitN(trimmed, "\n---\n", 2)
+	if len(parts) != 2 {
+		return Frontmatter{}, content
+	}
+	fmText := strings.TrimPrefix(parts[0], "---\n")
+	body := parts[1]
+
+	var fm Frontmatter
+	if err := yaml.Unmarshal([]byte(fmText), &fm); err != nil {
+		// If unmarshal fails, just return original content
+		return Frontmatter{}, content
+	}
+	return fm, body
+}
+
+// applyTo returns the theme with the frontmatter's overrides applied.
+func (fm Frontmatter) applyTo(theme theme.Theme) theme.Theme {
+	if t := strings.TrimSpace(fm.Transition); t != "" {
+		theme.Transition = t
+	}
+	if c := strings.TrimSpace(fm.Classification); c != "" {
+		theme.ClassificationLabel = c
+	}
+	if w := strings.TrimSpace(fm.Watermark); w != "" {
+		theme.Watermark = true
+		theme.WatermarkText = w
+	}
+	return theme
+}
+
+// values are what placeholders can refer to. title is the deck's page title,
+// which falls back to the theme's.
+func (fm Frontmatter) values(title string) map[string]any {
+	return map[string]any{
+		"title":          title,
+		"author":         fm.Author,
+		"date":           fm.Date,
+		"classification": fm.Classification,
+		"vars":           fm.Vars,
+	}
+}
+
+// expandPlaceholders replaces {{ .name }} placeholders outside code blocks.
+// Placeholders that don't name a value are left as they are, so typos show
+// up on the slide.
+func expandPlaceholders(slide string, values map[string]any) string {
+	if !strings.Contains(slide, "{{") {
+		return slide
+	}
+	lines := strings.Split(slide, "\n")
+	var fence codeFence
+	for i, line := range lines {
+		if fence.inCode(line) {
+			continue
+		}
+		lines[i] = placeholderRegex.ReplaceAllStringFunc(line, func(m string) string {
+			var v any = values
+			for _, key := range strings.Split(placeholderRegex.FindStringSubmatch(m)[1], ".") {
+				fields, ok := v.(map[string]any)
+				if !ok {
+					return m
+				}
+				if v, ok = fields[key]; !ok {
+					return m
+				}
+			}
+			switch v := v.(type) {
+			case nil, map[string]any, []any:
+				return m
+			case time.Time:
+				// YAML reads unquoted dates as timestamps; show them as written
+				if v.Equal(time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, v.Location())) {
+					return v.Format("2006-01-02")
+				}
+				return v.Format(time.RFC3339)
+			}
+			return fmt.Sprint(v)
+		})
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,26 @@
+package deck
+
+import "testing"
+
+func TestExpandPlaceholders(t *testing.T) {
+	fm, _ := ParseFrontmatter("---\ndate: 2024-05-01\nvars:\n  d: 2024-05-01\n  at: 2024-05-01T09:30:00Z\n  quoted: \"2024-05-01\"\n  n: 3\n  product:\n    name: Widget\n---\n# A\n")
+	values := fm.values("Review")
+	tests := []struct {
+		slide string
+		want  string
+	}{
+		{"{{ .date }}", "2024-05-01"},
+		{"{{ .vars.d }}", "2024-05-01"},
+		{"{{ .vars.at }}", "2024-05-01T09:30:00Z"},
+		{"{{ .vars.quoted }}", "2024-05-01"},
+		{"{{.vars.n}} of {{ .vars.product.name }}", "3 of Widget"},
+		{"{{ .title }}", "Review"},
+		{"{{ .vars.product }} {{ .vars.missing }}", "{{ .vars.product }} {{ .vars.missing }}"},
+		{"```\n{{ .vars.d }}\n```", "```\n{{ .vars.d }}\n```"},
+	}
+	for _, tt := range tests {
+		if got := expandPlaceholders(tt.slide, values); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.slide, got, tt.want)
+		}
+	}
+}
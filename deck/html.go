@@ -0,0 +1,202 @@
+package deck
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"slides/highlight"
+)
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// RenderHTML renders blocks the way CommonMark's reference renderer does.
+// assetBase is prepended to relative link and image targets so they resolve
+// to the deck's assets.
+func RenderHTML(blocks []Block, assetBase string) string {
+	b := &htmlWriter{assetBase: assetBase}
+	writeBlocks(b, blocks, false)
+	return b.String()
+}
+
+// htmlWriter collects rendered HTML along with what relative paths are
+// resolved against.
+type htmlWriter struct {
+	strings.Builder
+	assetBase string
+}
+
+// cr starts a new line unless the output is already at one.
+func cr(b *htmlWriter) {
+	if s := b.String(); s != "" && s[len(s)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+}
+
+// writeBlocks renders a block sequence. In tight lists paragraphs are
+// written without <p> tags. Blocks after a pause are wrapped in a fragment
+// that the browser reveals on the next step.
+func writeBlocks(b *htmlWriter, blocks []Block, tight bool) {
+	paused := false
+	for _, block := range blocks {
+		switch n := block.(type) {
+		case *Pause:
+			cr(b)
+			if paused {
+				b.WriteString("</div>\n")
+			}
+			b.WriteString(`<div class="fragment">` + "\n")
+			paused = true
+		case *Paragraph:
+			if tight {
+				writeInlines(b, n.Inlines)
+				continue
+			}
+			cr(b)
+			b.WriteString("<p>")
+			writeInlines(b, n.Inlines)
+			b.WriteString("</p>\n")
+		case *Heading:
+			cr(b)
+			fmt.Fprintf(b, "<h%d>", n.Level)
+			writeInlines(b, n.Inlines)
+			fmt.Fprintf(b, "</h%d>\n", n.Level)
+		case *CodeBlock:
+			cr(b)
+			b.WriteString("<pre")
+			if len(n.Steps) > 0 {
+				steps, _ := json.Marshal(n.Steps)
+				fmt.Fprintf(b, ` data-line-steps="%s"`, htmlEscaper.Replace(string(steps)))
+			}
+			b.WriteString("><code")
+			if n.Lang != "" {
+				fmt.Fprintf(b, ` class="language-%s"`, htmlEscaper.Replace(n.Lang))
+			}
+			b.WriteString(">")
+			if len(n.Steps) > 0 {
+				b.WriteString(highlight.LinesHTML(n.Code, n.Lang, n.Steps))
+			} else {
+				b.WriteString(highlight.HTML(n.Code, n.Lang))
+			}
+			b.WriteString("</code></pre>\n")
+		case *ThematicBreak:
+			cr(b)
+			b.WriteString("<hr />\n")
+		case *BlockQuote:
+			cr(b)
+			b.WriteString("<blockquote>\n")
+			writeBlocks(b, n.Blocks, false)
+			cr(b)
+			b.WriteString("</blockquote>\n")
+		case *List:
+			tag := "ul"
+			cr(b)
+			if n.Ordered {
+				tag = "ol"
+				if n.Start != 1 {
+					fmt.Fprintf(b, `<ol start="%d">`+"\n", n.Start)
+				} else {
+					b.WriteString("<ol>\n")
+				}
+			} else {
+				b.WriteString("<ul>\n")
+			}
+			for _, item := range n.Items {
+				if n.Incremental {
+					b.WriteString(`<li class="fragment">`)
+				} else {
+					b.WriteString("<li>")
+				}
+				writeBlocks(b, item.Blocks, n.Tight)
+				b.WriteString("</li>\n")
+			}
+			fmt.Fprintf(b, "</%s>\n", tag)
+		case *Table:
+			cr(b)
+			b.WriteString("<table>\n<thead>\n")
+			writeTableRow(b, "th", n.Header, n.Align)
+			b.WriteString("</thead>\n")
+			if len(n.Rows) > 0 {
+				b.WriteString("<tbody>\n")
+				for _, row := range n.Rows {
+					writeTableRow(b, "td", row, n.Align)
+				}
+				b.WriteString("</tbody>\n")
+			}
+			b.WriteString("</table>\n")
+		case *Columns:
+			widths := make([]string, len(n.Columns))
+			for i, col := range n.Columns {
+				widths[i] = col.Width
+			}
+			cr(b)
+			fmt.Fprintf(b, `<div class="columns" style="--columns: %s">`+"\n", htmlEscaper.Replace(strings.Join(widths, " ")))
+			for _, col := range n.Columns {
+				b.WriteString(`<div class="column">` + "\n")
+				writeBlocks(b, col.Blocks, false)
+				cr(b)
+				b.WriteString("</div>\n")
+			}
+			b.WriteString("</div>\n")
+		}
+	}
+	if paused {
+		cr(b)
+		b.WriteString("</div>\n")
+	}
+}
+
+// writeTableRow writes one <tr>. Alignment uses the align attribute so theme
+// CSS can still override it.
+func writeTableRow(b *htmlWriter, tag string, cells []*TableCell, align []string) {
+	b.WriteString("<tr>\n")
+	for i, cell := range cells {
+		if align[i] != "" {
+			fmt.Fprintf(b, `<%s align="%s">`, tag, align[i])
+		} else {
+			fmt.Fprintf(b, "<%s>", tag)
+		}
+		writeInlines(b, cell.Inlines)
+		fmt.Fprintf(b, "</%s>\n", tag)
+	}
+	b.WriteString("</tr>\n")
+}
+
+func writeInlines(b *htmlWriter, inlines []Inline) {
+	for _, inline := range inlines {
+		switch n := inline.(type) {
+		case *Text:
+			b.WriteString(htmlEscaper.Replace(n.Value))
+		case *SoftBreak:
+			b.WriteString("\n")
+		case *HardBreak:
+			b.WriteString("<br />\n")
+		case *CodeSpan:
+			b.WriteString("<code>")
+			b.WriteString(htmlEscaper.Replace(n.Code))
+			b.WriteString("</code>")
+		case *Emphasis:
+			b.WriteString("<em>")
+			writeInlines(b, n.Children)
+			b.WriteString("</em>")
+		case *Strong:
+			b.WriteString("<strong>")
+			writeInlines(b, n.Children)
+			b.WriteString("</strong>")
+		case *Link:
+			fmt.Fprintf(b, `<a href="%s"`, htmlEscaper.Replace(assetPath(n.Dest, b.assetBase, false)))
+			if n.Title != "" {
+				fmt.Fprintf(b, ` title="%s"`, htmlEscaper.Replace(n.Title))
+			}
+			b.WriteString(">")
+			writeInlines(b, n.Children)
+			b.WriteString("</a>")
+		case *Image:
+			fmt.Fprintf(b, `<img src="%s" alt="%s"`, htmlEscaper.Replace(assetPath(n.Src, b.assetBase, true)), htmlEscaper.Replace(PlainText(n.Children)))
+			if n.Title != "" {
+				fmt.Fprintf(b, ` title="%s"`, htmlEscaper.Replace(n.Title))
+			}
+			b.WriteString(" />")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package deck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A deck can be assembled from several files. A line of the form
+//
+//	!include sections/storage.md
+//
+// is replaced by the body of that file, with its path taken relative to the
+// file holding the directive. Included files may include others. Their
+// frontmatter is dropped except for vars, which are merged into the deck's;
+// when both set the same var, the including file wins.
+
+var includeRegex = regexp.MustCompile(`^ {0,3}!include[ \t]+(\S.*?)[ \t]*$`)
+
+// sourceText is markdown from File starting at Line, so problems with it can
+// be reported where the author will find them. File is empty for slides that
+// come from the theme.
+type sourceText struct {
+	Text    string
+	File    string
+	Line    int // 1-based
+	EndLine int // last line in File of a slide; 0 for a single line
+}
+
+// location is the "file:line" errors about the text are prefixed with.
+func (s sourceText) location() string {
+	if s.File == "" {
+		return "theme"
+	}
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// readSource splits the markdown file at path into its frontmatter and body
+// lines, splicing in included files. files collects every file read, the
+// deck's own first.
+func readSource(path string, content string, stack []string, files *[]string) (Frontmatter, []sourceText, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	stack = append(stack, abs)
+	*files = append(*files, abs)
+
+	fm, body := ParseFrontmatter(content)
+	first := 0 // lines of frontmatter before body
+	if len(body) != len(content) {
+		first = strings.Count(strings.TrimRightFunc(content, unicode.IsSpace), "\n") - strings.Count(body, "\n")
+	}
+
+	var lines []sourceText
+	var fence codeFence
+	for i, text := range strings.Split(body, "\n") {
+		line := sourceText{Text: text, File: path, Line: first + i + 1}
+		m := includeRegex.FindStringSubmatch(text)
+		if fence.inCode(text) || m == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		target := m[1]
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if targetAbs, err := filepath.Abs(target); err == nil {
+			for j, p := range stack {
+				if p == targetAbs {
+					cycle := append(append([]string{}, stack[j:]...), targetAbs)
+					for k := range cycle {
+						cycle[k] = filepath.Base(cycle[k])
+					}
+					return fm, nil, fmt.Errorf("%s: include cycle: %s", line.location(), strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		included, err := os.ReadFile(target)
+		if err != nil {
+			return fm, nil, fmt.Errorf("%s: include %s: %w", line.location(), m[1], err)
+		}
+		includedFM, includedLines, err := readSource(target, string(included), stack, files)
+		if err != nil {
+			return fm, nil, err
+		}
+		for k, v := range includedFM.Vars {
+			if _, ok := fm.Vars[k]; !ok {
+				if fm.Vars == nil {
+					fm.Vars = make(map[string]any)
+				}
+				fm.Vars[k] = v
+			}
+		}
+		lines = append(lines, includedLines...)
+	}
+	return fm, lines, nil
+}
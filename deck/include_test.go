@@ -0,0 +1,119 @@
+package deck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the named files under dir, making directories as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadSourceNestedInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sections/storage.md":     "---\nvars:\n  disk: ssd\n  name: storage\n---\n# Storage\n!include parts/disks.md\nend",
+		"sections/parts/disks.md": "Disks\n```\n!include not-a-directive.md\n```",
+	})
+	deck := filepath.Join(dir, "slides.md")
+	content := "---\nvars:\n  name: deck\n---\n# Intro\n!include sections/storage.md\n"
+
+	var files []string
+	fm, lines, err := readSource(deck, content, nil, &files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := filepath.Join(dir, "sections", "storage.md")
+	disks := filepath.Join(dir, "sections", "parts", "disks.md")
+	want := []sourceText{
+		{Text: "# Intro", File: deck, Line: 5},
+		{Text: "# Storage", File: storage, Line: 6},
+		{Text: "Disks", File: disks, Line: 1},
+		{Text: "```", File: disks, Line: 2},
+		{Text: "!include not-a-directive.md", File: disks, Line: 3},
+		{Text: "```", File: disks, Line: 4},
+		{Text: "end", File: storage, Line: 8},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines:\n got %+v\nwant %+v", lines, want)
+	}
+	if want := []string{deck, storage, disks}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files %q, want %q", files, want)
+	}
+	// The including file wins when both set a var
+	if want := map[string]any{"name": "deck", "disk": "ssd"}; !reflect.DeepEqual(fm.Vars, want) {
+		t.Errorf("vars %v, want %v", fm.Vars, want)
+	}
+}
+
+func TestReadSourceIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md":       "# A\n!include sub/b.md",
+		"sub/b.md":   "# B\n\n!include ../a.md",
+		"slides.md":  "!include a.md",
+		"self.md":    "!include self.md",
+		"missing.md": "# M\n!include sub/c.md",
+		"sub/c.md":   "one\ntwo\n!include gone.md",
+	})
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"slides.md", filepath.Join(dir, "sub", "b.md") + ":3: include cycle: a.md -> b.md -> a.md"},
+		{"self.md", filepath.Join(dir, "self.md") + ":1: include cycle: self.md -> self.md"},
+		// Errors inside an included file name that file and line
+		{"missing.md", filepath.Join(dir, "sub", "c.md") + ":3: include gone.md: open " + filepath.Join(dir, "sub", "gone.md") + ": "},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		_, _, err = readSource(path, string(content), nil, &files)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
+
+func TestLoadIncludedSlideLocations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"slides.md": "---\ntitle: T\n---\n# One\n\n---\n\n!include part.md\n",
+		"part.md":   "# Two\ntext\n\n---\n\n# Three\n",
+	})
+	d, err := Load(filepath.Join(dir, "slides.md"), Options{Theme: "dark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type location struct {
+		file          string
+		line, endLine int
+	}
+	var got []location
+	for _, slide := range d.Slides {
+		if slide.File != "" {
+			got = append(got, location{filepath.Base(slide.File), slide.Line, slide.EndLine})
+		}
+	}
+	want := []location{{"slides.md", 4, 4}, {"part.md", 1, 2}, {"part.md", 6, 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("slide locations %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,657 @@
+package deck
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The inline parser scans text once, keeping emphasis delimiters and link
+// brackets on stacks and resolving them as closers are found, as described in
+// the CommonMark spec's appendix.
+
+// inode is a node of the doubly linked list inlines are collected in while
+// parsing, so emphasis can wrap a range of them in place.
+type inode struct {
+	val        Inline
+	prev, next *inode
+}
+
+type inlineList struct {
+	head, tail *inode
+}
+
+func (l *inlineList) append(val Inline) *inode {
+	n := &inode{val: val, prev: l.tail}
+	if l.tail != nil {
+		l.tail.next = n
+	} else {
+		l.head = n
+	}
+	l.tail = n
+	return n
+}
+
+func (l *inlineList) insertAfter(at *inode, val Inline) *inode {
+	n := &inode{val: val, prev: at, next: at.next}
+	if at.next != nil {
+		at.next.prev = n
+	} else {
+		l.tail = n
+	}
+	at.next = n
+	return n
+}
+
+func (l *inlineList) unlink(n *inode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		l.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		l.tail = n.prev
+	}
+	n.prev, n.next = nil, nil
+}
+
+// cut removes the nodes strictly between from and to and returns them as
+// inlines.
+func (l *inlineList) cut(from, to *inode) []Inline {
+	var out []Inline
+	for n := from.next; n != nil && n != to; {
+		next := n.next
+		out = append(out, n.val)
+		l.unlink(n)
+		n = next
+	}
+	return mergeText(out)
+}
+
+// delimiter is a run of * or _ that may open or close emphasis.
+type delimiter struct {
+	node       *inode // the *Text holding the run
+	char       byte
+	count      int
+	origCount  int
+	canOpen    bool
+	canClose   bool
+	prev, next *delimiter
+}
+
+// bracket is an unmatched [ or ![ that may start a link or image.
+type bracket struct {
+	node         *inode
+	prev         *bracket
+	prevDelim    *delimiter
+	index        int // position just after the bracket
+	image        bool
+	active       bool
+	bracketAfter bool
+}
+
+type inlineParser struct {
+	src      string
+	pos      int
+	refs     map[string]linkRef
+	nodes    inlineList
+	delims   *delimiter
+	brackets *bracket
+}
+
+var (
+	entityRegex        = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	autolinkRegex      = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*>`)
+	emailAutolinkRegex = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	escapableRegex     = regexp.MustCompile(`\\[!"#$%&'()*+,\-./:;<=>?@\[\\\]^_` + "`" + `{|}~]|&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+const inlineSpecials = "\n\\`*_[]!<&"
+
+// ParseInlines parses the text of a paragraph or heading on its own, with
+// no link reference definitions to resolve against.
+func ParseInlines(src string) []Inline {
+	return parseInlines(src, nil)
+}
+
+// parseInlines parses the text of a paragraph or heading.
+func parseInlines(src string, refs map[string]linkRef) []Inline {
+	p := &inlineParser{src: src, refs: refs}
+	for p.pos < len(p.src) {
+		p.parseInline()
+	}
+	p.processEmphasis(nil)
+
+	var out []Inline
+	for n := p.nodes.head; n != nil; n = n.next {
+		out = append(out, n.val)
+	}
+	return mergeText(out)
+}
+
+// mergeText joins adjacent text nodes and drops empty ones.
+func mergeText(inlines []Inline) []Inline {
+	var out []Inline
+	for _, in := range inlines {
+		t, ok := in.(*Text)
+		if !ok {
+			out = append(out, in)
+			continue
+		}
+		if t.Value == "" {
+			continue
+		}
+		if len(out) > 0 {
+			if prev, ok := out[len(out)-1].(*Text); ok {
+				out[len(out)-1] = &Text{Value: prev.Value + t.Value}
+				continue
+			}
+		}
+		out = append(out, &Text{Value: t.Value})
+	}
+	return out
+}
+
+func (p *inlineParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *inlineParser) text(s string) *inode {
+	return p.nodes.append(&Text{Value: s})
+}
+
+func (p *inlineParser) parseInline() {
+	switch c := p.src[p.pos]; c {
+	case '\n':
+		p.parseNewline()
+	case '\\':
+		p.parseBackslash()
+	case '`':
+		p.parseBackticks()
+	case '*', '_':
+		p.parseDelimiters(c)
+	case '[':
+		p.pos++
+		p.addBracket(p.text("["), p.pos, false)
+	case '!':
+		p.pos++
+		if p.peek() == '[' {
+			p.pos++
+			p.addBracket(p.text("!["), p.pos, true)
+		} else {
+			p.text("!")
+		}
+	case ']':
+		p.parseCloseBracket()
+	case '<':
+		p.parseAutolink()
+	case '&':
+		p.parseEntity()
+	default:
+		end := p.pos + 1
+		for end < len(p.src) && !strings.ContainsRune(inlineSpecials, rune(p.src[end])) {
+			end++
+		}
+		p.text(p.src[p.pos:end])
+		p.pos = end
+	}
+}
+
+// parseNewline turns a line ending into a hard break when the line ended in
+// two or more spaces, and a soft break otherwise.
+func (p *inlineParser) parseNewline() {
+	p.pos++
+	hard := false
+	if last := p.nodes.tail; last != nil {
+		if t, ok := last.val.(*Text); ok {
+			trimmed := strings.TrimRight(t.Value, " ")
+			hard = len(t.Value)-len(trimmed) >= 2
+			t.Value = trimmed
+		}
+	}
+	if hard {
+		p.nodes.append(&HardBreak{})
+	} else {
+		p.nodes.append(&SoftBreak{})
+	}
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *inlineParser) parseBackslash() {
+	p.pos++
+	switch c := p.peek(); {
+	case c == '\n':
+		p.pos++
+		p.nodes.append(&HardBreak{})
+		for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+			p.pos++
+		}
+	case isASCIIPunct(c):
+		p.text(string(c))
+		p.pos++
+	default:
+		p.text("\\")
+	}
+}
+
+// parseBackticks reads a code span, which ends at the next backtick run of
+// the same length. Without one the backticks are literal.
+func (p *inlineParser) parseBackticks() {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == '`' {
+		p.pos++
+	}
+	ticks := p.pos - start
+	for i := p.pos; i < len(p.src); {
+		if p.src[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(p.src) && p.src[j] == '`' {
+			j++
+		}
+		if j-i == ticks {
+			code := strings.ReplaceAll(p.src[p.pos:i], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			p.nodes.append(&CodeSpan{Code: code})
+			p.pos = j
+			return
+		}
+		i = j
+	}
+	p.text(p.src[start:p.pos])
+}
+
+// parseDelimiters reads a run of * or _ and records whether it can open or
+// close emphasis, based on the characters around it.
+func (p *inlineParser) parseDelimiters(c byte) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+	count := p.pos - start
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+	}
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+	}
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := isPunct(before), isPunct(after)
+	leftFlanking := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	rightFlanking := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+
+	canOpen, canClose := leftFlanking, rightFlanking
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || beforePunct)
+		canClose = rightFlanking && (!leftFlanking || afterPunct)
+	}
+
+	n := p.text(p.src[start:p.pos])
+	if !canOpen && !canClose {
+		return
+	}
+	d := &delimiter{node: n, char: c, count: count, origCount: count, canOpen: canOpen, canClose: canClose, prev: p.delims}
+	if p.delims != nil {
+		p.delims.next = d
+	}
+	p.delims = d
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.delims = d.prev
+	}
+}
+
+// processEmphasis matches closers with openers above stackBottom and wraps
+// the inlines between them in Emphasis or Strong nodes.
+func (p *inlineParser) processEmphasis(stackBottom *delimiter) {
+	type bottomKey struct {
+		char    byte
+		canOpen bool
+		mod     int
+	}
+	openersBottom := make(map[bottomKey]*delimiter)
+
+	closer := p.delims
+	for closer != nil && closer.prev != stackBottom {
+		closer = closer.prev
+	}
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+		key := bottomKey{closer.char, closer.canOpen, closer.origCount % 3}
+		bottom, ok := openersBottom[key]
+		if !ok {
+			bottom = stackBottom
+		}
+
+		opener := closer.prev
+		found := false
+		for opener != nil && opener != stackBottom && opener != bottom {
+			oddMatch := (closer.canOpen || opener.canClose) && closer.origCount%3 != 0 && (opener.origCount+closer.origCount)%3 == 0
+			if opener.char == closer.char && opener.canOpen && !oddMatch {
+				found = true
+				break
+			}
+			opener = opener.prev
+		}
+
+		if !found {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.removeDelimiter(closer)
+			}
+			closer = next
+			continue
+		}
+
+		use := 1
+		if closer.count >= 2 && opener.count >= 2 {
+			use = 2
+		}
+		opener.count -= use
+		closer.count -= use
+		openText, closeText := opener.node.val.(*Text), closer.node.val.(*Text)
+		openText.Value = openText.Value[:len(openText.Value)-use]
+		closeText.Value = closeText.Value[:len(closeText.Value)-use]
+
+		children := p.nodes.cut(opener.node, closer.node)
+		var emph Inline = &Emphasis{Children: children}
+		if use == 2 {
+			emph = &Strong{Children: children}
+		}
+		p.nodes.insertAfter(opener.node, emph)
+
+		// Delimiters between opener and closer can no longer match
+		for d := closer.prev; d != nil && d != opener; {
+			prev := d.prev
+			p.removeDelimiter(d)
+			d = prev
+		}
+		if opener.count == 0 {
+			p.nodes.unlink(opener.node)
+			p.removeDelimiter(opener)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			p.nodes.unlink(closer.node)
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	for p.delims != nil && p.delims != stackBottom {
+		p.removeDelimiter(p.delims)
+	}
+}
+
+func (p *inlineParser) addBracket(n *inode, index int, image bool) {
+	if p.brackets != nil {
+		p.brackets.bracketAfter = true
+	}
+	p.brackets = &bracket{node: n, prev: p.brackets, prevDelim: p.delims, index: index, image: image, active: true}
+}
+
+// parseCloseBracket tries to turn the text since the last open bracket into
+// a link or image, with an inline destination or a reference.
+func (p *inlineParser) parseCloseBracket() {
+	p.pos++
+	start := p.pos
+	opener := p.brackets
+	if opener == nil {
+		p.text("]")
+		return
+	}
+	if !opener.active {
+		p.brackets = opener.prev
+		p.text("]")
+		return
+	}
+
+	var dest, title string
+	matched := false
+	if p.peek() == '(' {
+		pos := skipSpaceNewline(p.src, p.pos+1)
+		if d, end, ok := parseLinkDestination(p.src, pos); ok {
+			dest, pos = d, end
+			beforeTitle := pos
+			pos = skipSpaceNewline(p.src, pos)
+			if pos != beforeTitle {
+				if t, end, ok := parseLinkTitle(p.src, pos); ok {
+					title, pos = t, skipSpaceNewline(p.src, end)
+				}
+			}
+			if pos < len(p.src) && p.src[pos] == ')' {
+				p.pos = pos + 1
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		// Full, collapsed or shortcut reference
+		label := ""
+		n := parseLinkLabel(p.src[p.pos:])
+		switch {
+		case n > 2:
+			label = p.src[p.pos+1 : p.pos+n-1]
+		case !opener.bracketAfter:
+			label = p.src[opener.index : start-1]
+		}
+		if ref, ok := p.refs[normalizeLabel(label)]; ok && label != "" {
+			dest, title = ref.dest, ref.title
+			p.pos += n
+			matched = true
+		}
+	}
+
+	if !matched {
+		p.brackets = opener.prev
+		p.pos = start
+		p.text("]")
+		return
+	}
+
+	p.processEmphasis(opener.prevDelim)
+	children := p.nodes.cut(opener.node, nil)
+	p.nodes.unlink(opener.node)
+	if opener.image {
+		p.nodes.append(&Image{Src: dest, Title: title, Children: children})
+	} else {
+		p.nodes.append(&Link{Dest: dest, Title: title, Children: children})
+	}
+	p.brackets = opener.prev
+
+	// Links can't contain links
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+func (p *inlineParser) parseAutolink() {
+	rest := p.src[p.pos:]
+	if m := emailAutolinkRegex.FindStringSubmatch(rest); m != nil {
+		p.nodes.append(&Link{Dest: "mailto:" + m[1], Children: []Inline{&Text{Value: m[1]}}})
+		p.pos += len(m[0])
+		return
+	}
+	if m := autolinkRegex.FindString(rest); m != "" {
+		url := m[1 : len(m)-1]
+		p.nodes.append(&Link{Dest: url, Children: []Inline{&Text{Value: url}}})
+		p.pos += len(m)
+		return
+	}
+	p.text("<")
+	p.pos++
+}
+
+func (p *inlineParser) parseEntity() {
+	if m := entityRegex.FindString(p.src[p.pos:]); m != "" {
+		p.text(html.UnescapeString(m))
+		p.pos += len(m)
+		return
+	}
+	p.text("&")
+	p.pos++
+}
+
+// unescapeString resolves backslash escapes and entities, as needed for link
+// destinations, titles and code block info strings.
+func unescapeString(s string) string {
+	return escapableRegex.ReplaceAllStringFunc(s, func(m string) string {
+		if m[0] == '\\' {
+			return m[1:]
+		}
+		return html.UnescapeString(m)
+	})
+}
+
+// skipSpaceNewline skips spaces, tabs and at most one line ending.
+func skipSpaceNewline(s string, i int) int {
+	newline := false
+	for i < len(s) {
+		switch s[i] {
+		case ' ', '\t':
+		case '\n':
+			if newline {
+				return i
+			}
+			newline = true
+		default:
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// parseLinkLabel returns the length of the [label] at the start of s,
+// brackets included, or 0 if there is none.
+func parseLinkLabel(s string) int {
+	if len(s) == 0 || s[0] != '[' {
+		return 0
+	}
+	for i := 1; i < len(s) && i <= 1000; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return 0
+		case ']':
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// parseLinkDestination reads a <bracketed> or bare destination starting at
+// i, returning it unescaped together with the position after it.
+func parseLinkDestination(s string, i int) (string, int, bool) {
+	if i < len(s) && s[i] == '<' {
+		for j := i + 1; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '\n', '<':
+				return "", i, false
+			case '>':
+				return unescapeString(s[i+1 : j]), j + 1, true
+			}
+		}
+		return "", i, false
+	}
+
+	depth := 0
+	j := i
+loop:
+	for j < len(s) {
+		switch c := s[j]; {
+		case c == '\\' && j+1 < len(s) && isASCIIPunct(s[j+1]):
+			j += 2
+			continue
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case c <= ' ' || c == 0x7f:
+			break loop
+		}
+		j++
+	}
+	if depth != 0 || j == i && (j >= len(s) || s[j] != ')') {
+		return "", i, false
+	}
+	return unescapeString(s[i:j]), j, true
+}
+
+// parseLinkTitle reads a "double", 'single' or (parenthesized) title.
+func parseLinkTitle(s string, i int) (string, int, bool) {
+	if i >= len(s) {
+		return "", i, false
+	}
+	closing := s[i]
+	switch closing {
+	case '"', '\'':
+	case '(':
+		closing = ')'
+	default:
+		return "", i, false
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch c := s[j]; {
+		case c == '\\':
+			j++
+		case c == closing:
+			return unescapeString(s[i+1 : j]), j + 1, true
+		case c == '(' && closing == ')':
+			return "", i, false
+		case c == '\n':
+			// Titles can't contain a blank line
+			if rest := strings.TrimLeft(s[j+1:], " \t"); strings.HasPrefix(rest, "\n") {
+				return "", i, false
+			}
+		}
+	}
+	return "", i, false
+}
+
+func isASCIIPunct(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+func isPunct(r rune) bool {
+	if r < utf8.RuneSelf {
+		return isASCIIPunct(byte(r))
+	}
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,188 @@
+package deck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A fenced code block can take its code from a file instead of repeating it:
+//
+//	```go file=../pkg/server.go lines=40-72
+//	```
+//
+// region=NAME picks the lines between "#region NAME" and "#endregion"
+// comments instead of a line range. Paths are relative to the markdown file
+// holding the block, which for included files isn't the deck's, and the
+// language defaults to the file's extension.
+
+var (
+	snippetFenceRegex = regexp.MustCompile("^([ \t]*)(`{3,}|~{3,})(.*)$")
+	regionStartRegex  = regexp.MustCompile(`#region[ \t]+(\S+)`)
+	regionEndRegex    = regexp.MustCompile(`#endregion\b`)
+)
+
+// importSnippets fills code blocks that have a file= attribute with the code
+// they point to. Whatever the block held is replaced. files collects the
+// paths read so they can be watched.
+func importSnippets(lines []sourceText, files *[]string) ([]sourceText, error) {
+	var out []sourceText
+	for i := 0; i < len(lines); i++ {
+		m := snippetFenceRegex.FindStringSubmatch(lines[i].Text)
+		if m == nil || (m[2][0] == '`' && strings.Contains(m[3], "`")) {
+			out = append(out, lines[i])
+			continue
+		}
+		indent, fence, info := m[1], m[2], strings.TrimSpace(m[3])
+		closing := regexp.MustCompile("^[ \t]*" + regexp.QuoteMeta(fence[:1]) + "{" + strconv.Itoa(len(fence)) + ",}[ \t]*$")
+		end := i + 1
+		for end < len(lines) && !closing.MatchString(lines[end].Text) {
+			end++
+		}
+
+		attrs := fenceAttributes(info)
+		if attrs["file"] == "" {
+			// Not ours: copy the block through untouched
+			out = append(out, lines[i:min(end+1, len(lines))]...)
+			i = end
+			continue
+		}
+
+		code, err := readSnippet(filepath.Dir(lines[i].File), attrs, files)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lines[i].location(), err)
+		}
+		if first, _, _ := strings.Cut(info, " "); strings.Contains(first, "=") || strings.HasPrefix(first, "{") {
+			lang := strings.TrimPrefix(strings.ToLower(filepath.Ext(attrs["file"])), ".")
+			if strings.EqualFold(filepath.Base(attrs["file"]), "dockerfile") {
+				lang = "dockerfile"
+			}
+			info = strings.TrimSpace(lang + " " + info)
+		}
+		// The fence must be longer than any fence in the code
+		for _, line := range code {
+			if run := len(line) - len(strings.TrimLeft(line, fence[:1])); run >= len(fence) {
+				fence = strings.Repeat(fence[:1], run+1)
+			}
+		}
+
+		at := lines[i]
+		emit := func(text string) {
+			out = append(out, sourceText{Text: text, File: at.File, Line: at.Line})
+		}
+		emit(indent + fence + info)
+		for _, line := range code {
+			emit(indent + line)
+		}
+		emit(indent + fence)
+		i = end
+	}
+	return out, nil
+}
+
+// fenceAttributes picks the key=value words out of a fence's info string.
+func fenceAttributes(info string) map[string]string {
+	attrs := make(map[string]string)
+	for _, word := range strings.Fields(info) {
+		if key, value, ok := strings.Cut(word, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+// readSnippet reads the lines a code block's attributes select, without
+// their common indentation.
+func readSnippet(dir string, attrs map[string]string, files *[]string) ([]string, error) {
+	name := attrs["file"]
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("file=%s: %w", name, err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		*files = append(*files, abs)
+	}
+	all := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	var lines []string
+	switch rng, region := attrs["lines"], attrs["region"]; {
+	case rng != "" && region != "":
+		return nil, fmt.Errorf("file=%s: use lines= or region=, not both", name)
+	case rng != "":
+		from, to, isRange := strings.Cut(rng, "-")
+		start, err := strconv.Atoi(from)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(to)
+		}
+		if err != nil || start < 1 || end < start {
+			return nil, fmt.Errorf("file=%s: lines=%s is not a line number or a range like 40-72", name, rng)
+		}
+		if end > len(all) {
+			return nil, fmt.Errorf("file=%s: lines=%s is out of range, the file has %d lines", name, rng, len(all))
+		}
+		lines = all[start-1 : end]
+	case region != "":
+		start := -1
+		for n, line := range all {
+			if m := regionStartRegex.FindStringSubmatch(line); m != nil && m[1] == region {
+				start = n + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, fmt.Errorf("file=%s: region=%s: no \"#region %s\" comment in the file", name, region, region)
+		}
+		depth := 1
+		for _, line := range all[start:] {
+			if regionStartRegex.MatchString(line) {
+				depth++
+				continue
+			}
+			if regionEndRegex.MatchString(line) {
+				if depth--; depth == 0 {
+					break
+				}
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("file=%s: region=%s has no closing #endregion", name, region)
+		}
+	default:
+		lines = all
+	}
+	return dedent(lines), nil
+}
+
+// dedent removes the indentation all non-blank lines share.
+func dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimPrefix(line, prefix)
+	}
+	return out
+}
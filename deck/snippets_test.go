@@ -0,0 +1,138 @@
+package deck
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const snippetSource = `package server
+
+// #region handler
+func handle() {
+	// #region body
+	serve()
+	// #endregion
+}
+// #endregion
+
+func main() {
+	handle()
+}
+`
+
+// importBody runs importSnippets over body as if it were the markdown file
+// at path and returns the resulting text.
+func importBody(path, body string) (string, []string, error) {
+	var lines []sourceText
+	for i, text := range strings.Split(body, "\n") {
+		lines = append(lines, sourceText{Text: text, File: path, Line: i + 1})
+	}
+	var files []string
+	out, err := importSnippets(lines, &files)
+	if err != nil {
+		return "", nil, err
+	}
+	var text []string
+	for _, line := range out {
+		text = append(text, line.Text)
+	}
+	return strings.Join(text, "\n"), files, nil
+}
+
+func TestImportSnippets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/server.go": snippetSource,
+		"src/fence.md":  "````\n```\n````\n",
+	})
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"whole file", "```go file=src/server.go\nreplaced\n```", "```go file=src/server.go\n" + strings.TrimSuffix(snippetSource, "\n") + "\n```"},
+		{"language from extension", "``` file=src/server.go lines=1", "```go file=src/server.go lines=1\npackage server\n```"},
+		{"single line", "```go file=src/server.go lines=12\n```", "```go file=src/server.go lines=12\nhandle()\n```"},
+		{"line range", "```go file=src/server.go lines=11-13 {2}\n```", "```go file=src/server.go lines=11-13 {2}\nfunc main() {\n\thandle()\n}\n```"},
+		{"region", "```go file=src/server.go region=handler\n```", "```go file=src/server.go region=handler\nfunc handle() {\n\tserve()\n}\n```"},
+		{"nested region dedented", "```go file=src/server.go region=body\n```", "```go file=src/server.go region=body\nserve()\n```"},
+		{"indented block", "- item\n\n  ~~~go file=src/server.go lines=6\n  ~~~", "- item\n\n  ~~~go file=src/server.go lines=6\n  serve()\n  ~~~"},
+		{"fence lengthened", "```md file=src/fence.md\n```", "`````md file=src/fence.md\n````\n```\n````\n`````"},
+		{"no file attribute", "```go\n```go file=src/server.go\n```", "```go\n```go file=src/server.go\n```"},
+	}
+	for _, tt := range tests {
+		got, _, err := importBody(filepath.Join(dir, "slides.md"), tt.body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImportSnippetsRelativeToFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sections/code/server.go": snippetSource,
+	})
+	// A block in an included file names its snippet relative to that file
+	got, files, err := importBody(filepath.Join(dir, "sections", "storage.md"), "```go file=code/server.go lines=1\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "```go file=code/server.go lines=1\npackage server\n```"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := []string{filepath.Join(dir, "sections", "code", "server.go")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files %q, want %q", files, want)
+	}
+}
+
+func TestImportSnippetErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"server.go":   snippetSource,
+		"unclosed.go": "// #region open\nx := 1\n",
+	})
+	path := filepath.Join(dir, "slides.md")
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"# A\n```go file=missing.go\n```", path + ":2: file=missing.go: open " + filepath.Join(dir, "missing.go") + ": "},
+		{"```go file=server.go lines=40-72\n```", path + ":1: file=server.go: lines=40-72 is out of range, the file has 13 lines"},
+		{"```go file=server.go lines=5-2\n```", path + ":1: file=server.go: lines=5-2 is not a line number or a range like 40-72"},
+		{"```go file=server.go lines=0\n```", path + ":1: file=server.go: lines=0 is not a line number or a range like 40-72"},
+		{"```go file=server.go region=setup\n```", path + `:1: file=server.go: region=setup: no "#region setup" comment in the file`},
+		{"```go file=unclosed.go region=open\n```", path + ":1: file=unclosed.go: region=open has no closing #endregion"},
+		{"```go file=server.go lines=1 region=body\n```", path + ":1: file=server.go: use lines= or region=, not both"},
+	}
+	for _, tt := range tests {
+		_, _, err := importBody(path, tt.body)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.body, err, tt.want)
+		}
+	}
+}
+
+func TestDedent(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  []string
+	}{
+		{[]string{"\t\ta", "\t\t\tb", "", "\t\tc"}, []string{"a", "\tb", "", "c"}},
+		{[]string{"    a", "  b"}, []string{"  a", "b"}},
+		{[]string{"  a", "\tb"}, []string{"  a", "\tb"}},
+		{[]string{"a", "  b"}, []string{"a", "  b"}},
+		{[]string{"   ", "  a"}, []string{" ", "a"}},
+	}
+	for _, tt := range tests {
+		if got := dedent(tt.lines); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dedent(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package deck
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Slides are cut out of the markdown body at separator lines, at headings,
+// or at both. The frontmatter picks the rules:
+//
+//	split: both
+//	heading_level: 2
+//	vertical_separator: "^--$"
+//
+// Lines inside code blocks and ::: containers never split a slide.
+// Directive comments just above a heading that starts a slide go with it.
+
+// SplitRules decide where slides start. After loading, the Deck keeps the
+// rules that were applied, with "auto" resolved and defaults filled in.
+type SplitRules struct {
+	// Mode is "separator", "headings", "both" or "auto" (the default), which
+	// uses separators when the body has any and headings otherwise.
+	Mode string `yaml:"split" json:"mode"`
+	// Separator matches a line that ends a slide. Default "^---$".
+	Separator string `yaml:"separator" json:"separator"`
+	// HeadingLevel is the deepest heading level that starts a slide, 1-6.
+	// Default 6, every heading.
+	HeadingLevel int `yaml:"heading_level" json:"heading_level"`
+	// VerticalSeparator matches a line that starts a sub-slide under the
+	// current slide. Empty means no sub-slides.
+	VerticalSeparator string `yaml:"vertical_separator" json:"vertical_separator,omitempty"`
+}
+
+var (
+	splitHeadingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]|$)`)
+	splitFenceRegex   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+)
+
+// codeFence follows fenced code blocks line by line. As in CommonMark, a
+// block is only closed by a fence of the same character that is at least
+// as long as the one that opened it, so ```` can show ``` and ~~~ can
+// show either.
+type codeFence struct {
+	open string // the opening fence, empty outside code
+}
+
+// inCode reports whether line belongs to a code block, fences included.
+func (f *codeFence) inCode(line string) bool {
+	m := splitFenceRegex.FindStringSubmatch(line)
+	rest := ""
+	if m != nil {
+		rest = line[len(m[0]):]
+	}
+	if f.open == "" {
+		// A backtick fence's info string can't contain backticks
+		if m == nil || (m[1][0] == '`' && strings.Contains(rest, "`")) {
+			return false
+		}
+		f.open = m[1]
+		return true
+	}
+	if m != nil && m[1][0] == f.open[0] && len(m[1]) >= len(f.open) && strings.TrimSpace(rest) == "" {
+		f.open = ""
+	}
+	return true
+}
+
+// What a line of the body means for splitting.
+const (
+	lineContent = iota
+	lineSeparator
+	lineVertical
+	lineHeading
+)
+
+// parseMarkdown splits the deck body into sections. Each section is a slide
+// followed by the sub-slides split off it with the vertical separator. The
+// rules are returned with "auto" resolved and defaults filled in.
+func parseMarkdown(lines []sourceText, rules SplitRules) ([][]sourceText, SplitRules, error) {
+	if rules.Mode == "" {
+		rules.Mode = "auto"
+	}
+	if rules.Separator == "" {
+		rules.Separator = "^---$"
+	}
+	if rules.HeadingLevel == 0 {
+		rules.HeadingLevel = 6
+	}
+	switch {
+	case rules.Mode != "auto" && rules.Mode != "separator" && rules.Mode != "headings" && rules.Mode != "both":
+		return nil, rules, fmt.Errorf("split must be auto, separator, headings or both, not %q", rules.Mode)
+	case rules.HeadingLevel < 1 || rules.HeadingLevel > 6:
+		return nil, rules, fmt.Errorf("heading_level must be between 1 and 6, not %d", rules.HeadingLevel)
+	}
+	separator, err := regexp.Compile(rules.Separator)
+	if err != nil {
+		return nil, rules, fmt.Errorf("invalid separator: %w", err)
+	}
+	var vertical *regexp.Regexp
+	if rules.VerticalSeparator != "" {
+		if vertical, err = regexp.Compile(rules.VerticalSeparator); err != nil {
+			return nil, rules, fmt.Errorf("invalid vertical_separator: %w", err)
+		}
+	}
+
+	kinds := make([]int, len(lines))
+	hasSeparator := false
+	var fence codeFence
+	divDepth := 0
+	var divOpen sourceText // the outermost open ::: line
+	for i, source := range lines {
+		line := source.Text
+		trimmed := strings.TrimRight(line, " \t")
+		switch {
+		case fence.inCode(line):
+		case divFenceRegex.MatchString(strings.TrimSpace(line)):
+			if divDepth == 0 {
+				divOpen = source
+			}
+			divDepth++
+		case divDepth > 0:
+			if closingDivRegex.MatchString(strings.TrimSpace(line)) {
+				divDepth--
+			}
+		case vertical != nil && vertical.MatchString(trimmed):
+			kinds[i] = lineVertical
+		case separator.MatchString(trimmed):
+			kinds[i] = lineSeparator
+			hasSeparator = true
+		default:
+			if m := splitHeadingRegex.FindStringSubmatch(line); m != nil && len(m[1]) <= rules.HeadingLevel {
+				kinds[i] = lineHeading
+			}
+		}
+	}
+	if divDepth > 0 {
+		// Otherwise the rest of the deck would quietly become one slide
+		return nil, rules, fmt.Errorf("%s: %s is never closed with a ::: line", divOpen.location(), strings.TrimSpace(divOpen.Text))
+	}
+	if rules.Mode == "auto" {
+		rules.Mode = "headings"
+		if hasSeparator {
+			rules.Mode = "separator"
+		}
+	}
+
+	var sections [][]sourceText
+	var current []sourceText
+	sub := false // current is a sub-slide
+	flush := func() {
+		var text []string
+		var slide sourceText
+		for _, line := range current {
+			if strings.TrimSpace(line.Text) != "" {
+				if slide.Line == 0 {
+					slide = line
+				}
+				if line.File == slide.File {
+					slide.EndLine = line.Line
+				}
+			}
+			text = append(text, line.Text)
+		}
+		slide.Text = strings.TrimSpace(strings.Join(text, "\n"))
+		current = nil
+		switch {
+		case slide.Text == "" || onlyDirectives(slide.Text):
+		case sub && len(sections) > 0:
+			sections[len(sections)-1] = append(sections[len(sections)-1], slide)
+		default:
+			sections = append(sections, []sourceText{slide})
+		}
+	}
+	for i, line := range lines {
+		switch {
+		case kinds[i] == lineVertical:
+			flush()
+			sub = true
+			continue
+		case kinds[i] == lineSeparator && rules.Mode != "headings":
+			flush()
+			sub = false
+			continue
+		case kinds[i] == lineHeading && rules.Mode != "separator":
+			// Directives written just above the heading are the new slide's.
+			// A heading right after a separator belongs to the slide the
+			// separator started.
+			at := directivesStart(current)
+			directives := append([]sourceText(nil), current[at:]...)
+			if current = current[:at]; hasContent(current) {
+				flush()
+				sub = false
+			}
+			current = append(current, directives...)
+		}
+		current = append(current, line)
+	}
+	flush()
+	return sections, rules, nil
+}
+
+// directivesStart returns where the directive comments that end lines
+// begin, or len(lines) when they don't end with any.
+func directivesStart(lines []sourceText) int {
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i].Text), "<!--") {
+			continue
+		}
+		var text []string
+		for _, line := range lines[i:] {
+			text = append(text, line.Text)
+		}
+		if !onlyDirectives(strings.Join(text, "\n")) {
+			break
+		}
+		start = i
+	}
+	return start
+}
+
+// hasContent reports whether any of the lines is not blank.
+func hasContent(lines []sourceText) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
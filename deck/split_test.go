@@ -0,0 +1,273 @@
+package deck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMarkdownSplits(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules SplitRules
+		body  string
+		want  [][]string // slide texts per section, the first being the slide itself
+		mode  string     // the resolved mode
+	}{
+		{
+			name: "auto with separators",
+			body: "# A\none\n---\n# B\n## B2\n---\nC",
+			want: [][]string{{"# A\none"}, {"# B\n## B2"}, {"C"}},
+			mode: "separator",
+		},
+		{
+			name: "auto without separators",
+			body: "# A\none\n## B\ntwo\n### C",
+			want: [][]string{{"# A\none"}, {"## B\ntwo"}, {"### C"}},
+			mode: "headings",
+		},
+		{
+			name: "directive above heading",
+			body: "# A\n\ntext\n\n<!-- hidden: true -->\n# B\n\nsecret\n\n# C\n",
+			want: [][]string{{"# A\n\ntext"}, {"<!-- hidden: true -->\n# B\n\nsecret"}, {"# C"}},
+			mode: "headings",
+		},
+		{
+			name: "directives at the top",
+			body: "<!-- class: intro -->\n<!--\nlayout: center\n-->\n# A\n\n# B\n",
+			want: [][]string{{"<!-- class: intro -->\n<!--\nlayout: center\n-->\n# A"}, {"# B"}},
+			mode: "headings",
+		},
+		{
+			name: "other comments stay put",
+			body: "# A\n<!-- notes -->\nsay hi\n<!-- /notes -->\n# B",
+			want: [][]string{{"# A\n<!-- notes -->\nsay hi\n<!-- /notes -->"}, {"# B"}},
+			mode: "headings",
+		},
+		{
+			name: "directive-only slide dropped",
+			body: "A\n---\n<!-- class: x -->\n---\nB",
+			want: [][]string{{"A"}, {"B"}},
+			mode: "separator",
+		},
+		{
+			name:  "separator ignores headings",
+			rules: SplitRules{Mode: "separator"},
+			body:  "# A\n# B\n---\n# C",
+			want:  [][]string{{"# A\n# B"}, {"# C"}},
+			mode:  "separator",
+		},
+		{
+			name:  "headings ignores separators",
+			rules: SplitRules{Mode: "headings"},
+			body:  "# A\none\n---\ntwo\n# B",
+			want:  [][]string{{"# A\none\n---\ntwo"}, {"# B"}},
+			mode:  "headings",
+		},
+		{
+			name:  "both",
+			rules: SplitRules{Mode: "both"},
+			body:  "intro\n---\n# A\none\n# B\n---\nend",
+			want:  [][]string{{"intro"}, {"# A\none"}, {"# B"}, {"end"}},
+			mode:  "both",
+		},
+		{
+			name:  "heading after separator stays with its slide",
+			rules: SplitRules{Mode: "both"},
+			body:  "# A\n\n---\n\n# B\nbody",
+			want:  [][]string{{"# A"}, {"# B\nbody"}},
+			mode:  "both",
+		},
+		{
+			name:  "custom separator",
+			rules: SplitRules{Separator: `^\*\*\*+$`},
+			body:  "A\n***\nB\n---\nC\n*****",
+			want:  [][]string{{"A"}, {"B\n---\nC"}},
+			mode:  "separator",
+		},
+		{
+			name: "separator with trailing spaces",
+			body: "A\n---  \nB",
+			want: [][]string{{"A"}, {"B"}},
+			mode: "separator",
+		},
+		{
+			name:  "heading level",
+			rules: SplitRules{HeadingLevel: 2},
+			body:  "# A\n## B\n### B1\n#### B2\n## C",
+			want:  [][]string{{"# A"}, {"## B\n### B1\n#### B2"}, {"## C"}},
+			mode:  "headings",
+		},
+		{
+			name:  "not a heading",
+			rules: SplitRules{Mode: "headings"},
+			body:  "# A\n#hashtag\n    # indented\n# B",
+			want:  [][]string{{"# A\n#hashtag\n    # indented"}, {"# B"}},
+			mode:  "headings",
+		},
+		{
+			name:  "vertical separator",
+			rules: SplitRules{VerticalSeparator: "^--$"},
+			body:  "A\n--\nA1\n--\nA2\n---\nB\n--\nB1",
+			want:  [][]string{{"A", "A1", "A2"}, {"B", "B1"}},
+			mode:  "separator",
+		},
+		{
+			name:  "vertical separator before any slide",
+			rules: SplitRules{VerticalSeparator: "^--$"},
+			body:  "--\nA\n---\nB",
+			want:  [][]string{{"A"}, {"B"}},
+			mode:  "separator",
+		},
+		{
+			name:  "heading ends sub-slides",
+			rules: SplitRules{Mode: "both", VerticalSeparator: "^--$"},
+			body:  "# A\n--\nA1\n# B",
+			want:  [][]string{{"# A", "A1"}, {"# B"}},
+			mode:  "both",
+		},
+		{
+			name: "separator in backtick fence",
+			body: "A\n```yaml\n---\n# not a heading\n```\n---\nB",
+			want: [][]string{{"A\n```yaml\n---\n# not a heading\n```"}, {"B"}},
+			mode: "separator",
+		},
+		{
+			name: "separator in tilde fence",
+			body: "A\n~~~\n```\n---\n~~~\n---\nB",
+			want: [][]string{{"A\n~~~\n```\n---\n~~~"}, {"B"}},
+			mode: "separator",
+		},
+		{
+			name: "only code separators",
+			body: "# A\n````md\n```\n---\n```\n````\n# B",
+			want: [][]string{{"# A\n````md\n```\n---\n```\n````"}, {"# B"}},
+			mode: "headings",
+		},
+		{
+			name: "separator in columns",
+			body: "A\n::: columns\n::: column\n---\n# left\n:::\n:::\n---\nB",
+			want: [][]string{{"A\n::: columns\n::: column\n---\n# left\n:::\n:::"}, {"B"}},
+			mode: "separator",
+		},
+		{
+			name: "empty slides dropped",
+			body: "---\n\n---\nA\n---\n\n",
+			want: [][]string{{"A"}},
+			mode: "separator",
+		},
+	}
+	for _, tt := range tests {
+		var lines []sourceText
+		for i, text := range strings.Split(tt.body, "\n") {
+			lines = append(lines, sourceText{Text: text, File: "slides.md", Line: i + 1})
+		}
+		sections, rules, err := parseMarkdown(lines, tt.rules)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got [][]string
+		for _, section := range sections {
+			var texts []string
+			for _, slide := range section {
+				texts = append(texts, slide.Text)
+			}
+			got = append(got, texts)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+		if rules.Mode != tt.mode {
+			t.Errorf("%s: mode %q, want %q", tt.name, rules.Mode, tt.mode)
+		}
+	}
+}
+
+func TestParseMarkdownSlideLines(t *testing.T) {
+	var lines []sourceText
+	for i, text := range strings.Split("\n# A\none\n\n---\n\nB\n", "\n") {
+		lines = append(lines, sourceText{Text: text, File: "slides.md", Line: i + 1})
+	}
+	sections, _, err := parseMarkdown(lines, SplitRules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]int
+	for _, section := range sections {
+		got = append(got, [2]int{section[0].Line, section[0].EndLine})
+	}
+	if want := [][2]int{{2, 3}, {7, 7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slide lines %v, want %v", got, want)
+	}
+}
+
+func TestParseMarkdownInvalidRules(t *testing.T) {
+	tests := []struct {
+		rules SplitRules
+		want  string
+	}{
+		{SplitRules{Mode: "pages"}, `split must be auto, separator, headings or both, not "pages"`},
+		{SplitRules{HeadingLevel: 7}, "heading_level must be between 1 and 6, not 7"},
+		{SplitRules{Separator: "("}, "invalid separator"},
+		{SplitRules{VerticalSeparator: "["}, "invalid vertical_separator"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseMarkdown(nil, tt.rules)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got error %v, want %q", tt.rules, err, tt.want)
+		}
+	}
+}
+
+func TestDirectivesAboveHeadings(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string // titles and classes of the shown slides
+	}{
+		{"# A\n\ntext\n\n<!-- hidden: true -->\n# B\n\nsecret\n\n# C\n", []string{"A:", "C:"}},
+		{"<!-- class: intro -->\n# A\n\n# B\n", []string{"A:intro", "B:"}},
+	}
+	for _, tt := range tests {
+		d, err := Parse(strings.NewReader(tt.body), Options{Theme: "dark"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, slide := range d.Slides {
+			if slide.File != "" {
+				got = append(got, slide.Title+":"+slide.Class)
+			}
+			if strings.Contains(string(slide.Content), "&lt;!--") {
+				t.Errorf("%q: directive left on a slide: %s", tt.body, slide.Content)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: slides %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestParseMarkdownUnclosedContainer(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"# One\n\n::: columns\n\ntext\n\n---\n\n# Two\n\n---\n\n# Three\n", "slides.md:3: ::: columns is never closed with a ::: line"},
+		{"# One\n::: columns\n::: col\nleft\n:::\n---\n# Two", "slides.md:2: ::: columns is never closed with a ::: line"},
+		{"# One\n```\n::: columns\n```\n---\n# Two", ""},
+	}
+	for _, tt := range tests {
+		var lines []sourceText
+		for i, text := range strings.Split(tt.body, "\n") {
+			lines = append(lines, sourceText{Text: text, File: "slides.md", Line: i + 1})
+		}
+		got := ""
+		if _, _, err := parseMarkdown(lines, SplitRules{}); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%q: got error %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
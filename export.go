@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"slides/deck"
+	"slides/render"
+)
+
+// inlineAssetRegex matches image sources in HTML rendered from a deck loaded
+// with the asset base "assets/".
+var inlineAssetRegex = regexp.MustCompile(`src="assets/([^"]+)"`)
+
+// exportHTML writes the deck as one HTML file with the theme CSS inlined and
+// every local image (including the theme logo) embedded as a data: URI, so it
+// opens offline in any browser.
+func exportHTML(deck *deck.Deck, assetDir, outPath string, opts render.Options) error {
+	var page bytes.Buffer
+	if err := render.HTML(&page, deck, opts); err != nil {
+		return fmt.Errorf("render slides: %w", err)
+	}
+
+	out := inlineAssetRegex.ReplaceAllStringFunc(page.String(), func(match string) string {
+		ref := html.UnescapeString(inlineAssetRegex.FindStringSubmatch(match)[1])
+		rel := filepath.FromSlash(ref)
+		if !filepath.IsLocal(rel) {
+			log.Printf("Not inlining %s: outside %s", ref, assetDir)
+			return match
+		}
+		data, err := os.ReadFile(filepath.Join(assetDir, rel))
+		if err != nil {
+			log.Printf("Not inlining %s: %v", ref, err)
+			return match
+		}
+		return fmt.Sprintf(`src="%s"`, dataURI(rel, data))
+	})
+
+	return os.WriteFile(outPath, []byte(out), 0o644)
+}
+
+// dataURI encodes data as a base64 data: URI, taking the MIME type from the
+// file extension and falling back to content sniffing.
+func dataURI(name string, data []byte) string {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,329 @@
+// Package highlight colors code blocks in Go so decks work without
+// JavaScript libraries or network access. Each language is a small lexer: an
+// ordered list of patterns tried at every position, plus word lists that
+// classify identifiers. Tokens are wrapped in <span class="tok-KIND"> and
+// colored by the theme's syntax palette.
+package highlight
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Token is a piece of highlighted code. Kind is empty for plain text.
+type Token struct {
+	Kind string
+	Text string
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Kinds are the classes a theme's syntax palette can color.
+var Kinds = []string{"comment", "keyword", "string", "number", "literal", "type", "builtin", "function", "variable", "key"}
+
+// tokenRule emits Kind for text matching Pattern. When the pattern has a
+// group only the group is consumed, which stands in for lookahead. Rules
+// with LineStart only apply when nothing but indentation or a "- " list
+// marker precedes them on the line.
+type tokenRule struct {
+	Kind      string
+	Pattern   *regexp.Regexp
+	LineStart bool
+}
+
+type lexer struct {
+	rules           []tokenRule
+	ident           *regexp.Regexp
+	caseInsensitive bool
+	words           map[string]string // identifier → kind
+}
+
+func rule(kind, pattern string) tokenRule {
+	return tokenRule{Kind: kind, Pattern: regexp.MustCompile(`\A(?:` + pattern + `)`)}
+}
+
+func lineRule(kind, pattern string) tokenRule {
+	r := rule(kind, pattern)
+	r.LineStart = true
+	return r
+}
+
+// words maps each space-separated word to kind.
+func words(m map[string]string, kind, list string) map[string]string {
+	if m == nil {
+		m = make(map[string]string)
+	}
+	for _, w := range strings.Fields(list) {
+		m[w] = kind
+	}
+	return m
+}
+
+const (
+	numberPattern       = `0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|\d[\d_]*(?:\.\d+)?(?:[eE][+-]?\d+)?`
+	doubleQuotedPattern = `"(?:[^"\\\n]|\\.)*"`
+	singleQuotedPattern = `'(?:[^'\\\n]|\\.)*'`
+	cBlockComment       = `(?s:/\*.*?(?:\*/|\z))`
+)
+
+var identRegex = regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_]*`)
+
+var lexers = map[string]*lexer{
+	"go": {
+		rules: []tokenRule{
+			rule("comment", `//[^\n]*|`+cBlockComment),
+			rule("string", doubleQuotedPattern+"|`[^`]*`|"+singleQuotedPattern),
+			rule("number", numberPattern),
+		},
+		words: words(words(words(words(nil,
+			"keyword", "break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+			"type", "bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any comparable"),
+			"builtin", "append cap clear close complex copy delete imag len make max min new panic print println real recover"),
+			"literal", "true false nil iota"),
+	},
+	"bash": {
+		rules: []tokenRule{
+			lineRule("comment", `#[^\n]*`),
+			rule("comment", `[ \t]+(#[^\n]*)`),
+			rule("string", doubleQuotedPattern+`|'[^']*'`),
+			rule("variable", `\$\{[^}\n]*\}|\$[A-Za-z_][A-Za-z0-9_]*|\$[0-9#?@*$!-]`),
+			rule("number", `\b\d+\b`),
+		},
+		ident: regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_-]*`),
+		words: words(words(nil,
+			"keyword", "if then else elif fi for while until do done case esac in function select return break continue time"),
+			"builtin", "alias cd declare echo eval exec exit export local printf pwd read readonly set shift source test trap type unset"),
+	},
+	"yaml": {
+		rules: []tokenRule{
+			lineRule("comment", `#[^\n]*`),
+			rule("comment", `[ \t]+(#[^\n]*)`),
+			lineRule("key", `([^\s#:'"{}\[\],&*!|>-][^\n:#]*?)\s*:(?:[ \t]|\n|\z)`),
+			lineRule("key", `(`+doubleQuotedPattern+`|`+singleQuotedPattern+`)\s*:(?:[ \t]|\n|\z)`),
+			rule("string", doubleQuotedPattern+`|`+singleQuotedPattern),
+			rule("type", `[&*!][^\s,\[\]{}]+`),
+			rule("number", `([+-]?(?:`+numberPattern+`))[ \t]*(?:#|\n|\z)`),
+		},
+		ident: regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_-]*`),
+		words: words(nil, "literal", "true false null yes no on off True False Null"),
+	},
+	"json": {
+		rules: []tokenRule{
+			rule("key", `(`+doubleQuotedPattern+`)\s*:`),
+			rule("string", doubleQuotedPattern),
+			rule("number", `-?(?:`+numberPattern+`)`),
+		},
+		words: words(nil, "literal", "true false null"),
+	},
+	"python": {
+		rules: []tokenRule{
+			rule("comment", `#[^\n]*`),
+			rule("string", `(?i:[rbuf]{0,2})(?s:""".*?(?:"""|\z)|'''.*?(?:'''|\z))|(?i:[rbuf]{0,2})(?:`+doubleQuotedPattern+`|`+singleQuotedPattern+`)`),
+			rule("function", `@[A-Za-z_][A-Za-z0-9_.]*`),
+			rule("number", numberPattern),
+		},
+		words: words(words(words(words(nil,
+			"keyword", "and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield match case"),
+			"literal", "True False None"),
+			"builtin", "abs all any bool dict dir enumerate filter float format getattr hasattr int isinstance len list map max min next open print range repr reversed round set sorted str sum super tuple type zip self cls"),
+			"type", "Exception ValueError TypeError KeyError IndexError RuntimeError"),
+	},
+	"javascript": {
+		rules: []tokenRule{
+			rule("comment", `//[^\n]*|`+cBlockComment),
+			rule("string", doubleQuotedPattern+`|`+singleQuotedPattern+"|(?s:`(?:[^`\\\\]|\\\\.)*`)"),
+			rule("number", numberPattern+`n?`),
+		},
+		ident: regexp.MustCompile(`\A[A-Za-z_$][A-Za-z0-9_$]*`),
+		words: words(words(words(words(nil,
+			"keyword", "async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield"),
+			"literal", "true false null undefined NaN Infinity"),
+			"type", "Array Boolean Date Error Map Number Object Promise RegExp Set String Symbol"),
+			"builtin", "console document window JSON Math require module process"),
+	},
+	"sql": {
+		rules: []tokenRule{
+			rule("comment", `--[^\n]*|`+cBlockComment),
+			rule("string", `'(?:[^']|'')*'`),
+			rule("variable", `"(?:[^"]|"")*"|[:@$][A-Za-z_][A-Za-z0-9_]*`),
+			rule("number", numberPattern),
+		},
+		caseInsensitive: true,
+		words: words(words(words(words(nil,
+			"keyword", "add alter and as asc begin between by case check column commit constraint create cross default delete desc distinct drop else end exists foreign from full group having if in index inner insert into is join key left like limit not offset on or order outer primary references returning right rollback select set table then transaction union unique update using values view when where with"),
+			"type", "bigint bool boolean char date decimal double float int integer json jsonb numeric real serial smallint text time timestamp uuid varchar"),
+			"builtin", "avg coalesce count lower max min now nullif sum upper"),
+			"literal", "true false null"),
+	},
+	"dockerfile": {
+		rules: []tokenRule{
+			lineRule("comment", `#[^\n]*`),
+			lineRule("keyword", `(?i:from|run|cmd|label|maintainer|expose|env|add|copy|entrypoint|volume|user|workdir|arg|onbuild|stopsignal|healthcheck|shell)\b`),
+			rule("string", doubleQuotedPattern+`|`+singleQuotedPattern),
+			rule("variable", `\$\{[^}\n]*\}|\$[A-Za-z_][A-Za-z0-9_]*`),
+			rule("number", `\b\d+\b`),
+		},
+		ident: regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_-]*`),
+		words: words(nil, "keyword", "AS as"),
+	},
+}
+
+// lexerAliases maps info-string languages to lexers.
+var lexerAliases = map[string]string{
+	"golang": "go",
+	"sh":     "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"yml": "yaml",
+	"py":  "python", "python3": "python",
+	"js": "javascript", "jsx": "javascript", "mjs": "javascript", "ts": "javascript", "typescript": "javascript", "node": "javascript",
+	"postgres": "sql", "postgresql": "sql", "mysql": "sql", "sqlite": "sql",
+	"docker": "dockerfile",
+}
+
+func lexerFor(lang string) *lexer {
+	lang = strings.ToLower(lang)
+	if alias, ok := lexerAliases[lang]; ok {
+		lang = alias
+	}
+	return lexers[lang]
+}
+
+// Tokens splits code into tokens. Unknown languages come back as one
+// plain token.
+func Tokens(code, lang string) []Token {
+	lx := lexerFor(lang)
+	if lx == nil {
+		return []Token{{Text: code}}
+	}
+	ident := lx.ident
+	if ident == nil {
+		ident = identRegex
+	}
+
+	var tokens []Token
+	emit := func(kind, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{kind, text})
+	}
+
+	lineStart := 0
+	for pos := 0; pos < len(code); {
+		if code[pos] == '\n' {
+			emit("", "\n")
+			pos++
+			lineStart = pos
+			continue
+		}
+		rest := code[pos:]
+		atLineStart := strings.Trim(code[lineStart:pos], " \t-") == ""
+
+		matched := false
+		for _, r := range lx.rules {
+			if r.LineStart && !atLineStart {
+				continue
+			}
+			m := r.Pattern.FindStringSubmatchIndex(rest)
+			if m == nil || m[1] == 0 {
+				continue
+			}
+			start, end := m[0], m[1]
+			if len(m) > 2 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			// Text before the group is context the pattern needed, not the token
+			emit("", rest[:start])
+			emit(r.Kind, rest[start:end])
+			pos += end
+			matched = true
+			break
+		}
+		if matched {
+			continue
+		}
+
+		if word := ident.FindString(rest); word != "" {
+			key := word
+			if lx.caseInsensitive {
+				key = strings.ToLower(word)
+			}
+			kind := lx.words[key]
+			if kind == "" && strings.HasPrefix(strings.TrimLeft(rest[len(word):], " "), "(") {
+				kind = "function"
+			}
+			emit(kind, word)
+			pos += len(word)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(rest)
+		emit("", rest[:size])
+		pos += size
+	}
+	return tokens
+}
+
+// HTML renders code as escaped HTML with token spans.
+func HTML(code, lang string) string {
+	var b strings.Builder
+	writeTokens(&b, Tokens(code, lang))
+	return b.String()
+}
+
+// LinesHTML is HTML with every line wrapped in a
+// <span class="line">, so the browser can step through line highlights.
+// Lines of the first step start out highlighted.
+func LinesHTML(code, lang string, steps [][]int) string {
+	first := map[int]bool{}
+	for _, n := range steps[0] {
+		first[n] = true
+	}
+	lines := Lines(Tokens(code, lang))
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		class := "line"
+		if first[i+1] {
+			class += " highlighted"
+		}
+		fmt.Fprintf(&b, `<span class="%s" data-line="%d">`, class, i+1)
+		writeTokens(&b, line)
+		b.WriteString("\n</span>")
+	}
+	return b.String()
+}
+
+func writeTokens(b *strings.Builder, tokens []Token) {
+	for _, t := range tokens {
+		if t.Kind == "" {
+			b.WriteString(htmlEscaper.Replace(t.Text))
+			continue
+		}
+		fmt.Fprintf(b, `<span class="tok-%s">%s</span>`, t.Kind, htmlEscaper.Replace(t.Text))
+	}
+}
+
+// Lines splits tokens at line breaks, for exporters that lay out code
+// line by line.
+func Lines(tokens []Token) [][]Token {
+	lines := [][]Token{nil}
+	for _, t := range tokens {
+		parts := strings.Split(t.Text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], Token{t.Kind, part})
+			}
+		}
+	}
+	return lines
+}
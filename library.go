@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slides/render"
+	"slides/theme"
+)
+
+// library serves every deck under a directory: an index page at / and each
+// deck under /deck/<name>/, where name is the markdown file's path relative
+// to the directory without ".md". Every deck has its own presentation state,
+// events and assets, exactly as if it were served on its own.
+type library struct {
+	cfgPath     string
+	dir         string
+	theme       string
+	talkLength  time.Duration
+	driverToken string
+	watching    bool
+
+	mu    sync.RWMutex
+	decks map[string]*libraryDeck
+}
+
+// libraryDeck is one deck of a library. A deck that has never loaded has no
+// server; one that fails to reload keeps serving its last good version.
+type libraryDeck struct {
+	name    string
+	srv     *server
+	handler http.Handler
+	err     error
+}
+
+// reload rescans the directory and reloads every deck, telling open
+// browsers of decks that were already loaded to refresh. Files that another
+// deck includes are sections rather than decks and are left out.
+func (l *library) reload() error {
+	paths, err := findDecks(l.dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", l.dir, err)
+	}
+
+	l.mu.RLock()
+	old := l.decks
+	l.mu.RUnlock()
+
+	decks := make(map[string]*libraryDeck)
+	included := make(map[string]bool)
+	for name, path := range paths {
+		d := old[name]
+		if d == nil || d.srv == nil {
+			d = &libraryDeck{name: name}
+			srv := &server{
+				cfgPath:     l.cfgPath,
+				mdPath:      path,
+				theme:       l.theme,
+				base:        "/deck/" + name + "/",
+				searchURL:   "/api/search",
+				assetBase:   "assets/", // relative to the deck's page
+				assetDir:    filepath.Dir(path),
+				talkLength:  l.talkLength,
+				driverToken: l.driverToken,
+				hub:         newHub(),
+				watching:    l.watching,
+			}
+			if d.err = srv.reload(); d.err == nil {
+				d.srv, d.handler = srv, srv.routes()
+			}
+		} else {
+			d = &libraryDeck{name: name, srv: d.srv, handler: d.handler}
+			if d.err = d.srv.reload(); d.err == nil {
+				d.srv.hub.publish(event{Name: "reload"})
+			}
+		}
+		if d.err != nil {
+			log.Printf("Deck %s: %v", name, d.err)
+		}
+		if d.srv != nil {
+			for _, file := range d.srv.current().Files[1:] {
+				included[file] = true
+			}
+		}
+		decks[name] = d
+	}
+	for name, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil && included[abs] {
+			delete(decks, name)
+		}
+	}
+
+	l.mu.Lock()
+	l.decks = decks
+	l.mu.Unlock()
+	return nil
+}
+
+// files lists the files outside the directory that decks read, for -watch.
+func (l *library) files() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var files []string
+	for _, d := range l.decks {
+		if d.srv != nil {
+			files = append(files, d.srv.current().Files...)
+		}
+	}
+	return files
+}
+
+// findDecks maps deck names to the markdown files under dir, skipping
+// hidden directories such as .git.
+func findDecks(dir string) (map[string]string, error) {
+	paths := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths[filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))] = path
+		return nil
+	})
+	return paths, err
+}
+
+func (l *library) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		l.serveIndex(w)
+		return
+	case "/api/search":
+		l.serveSearch(w, r)
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/deck/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Names can contain slashes, so the longest matching name wins
+	l.mu.RLock()
+	var d *libraryDeck
+	for name, candidate := range l.decks {
+		if (rest == name || strings.HasPrefix(rest, name+"/")) && (d == nil || len(name) > len(d.name)) {
+			d = candidate
+		}
+	}
+	l.mu.RUnlock()
+
+	switch {
+	case d == nil:
+		http.NotFound(w, r)
+	case rest == d.name:
+		// Keep the query so ?slide= links to a deck still land on the slide
+		target := r.URL.Path + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	case d.srv == nil:
+		http.Error(w, d.err.Error(), http.StatusInternalServerError)
+	default:
+		http.StripPrefix("/deck/"+d.name, d.handler).ServeHTTP(w, r)
+	}
+}
+
+// serveIndex lists the decks with their frontmatter, colored like the
+// -theme theme.
+func (l *library) serveIndex(w http.ResponseWriter) {
+	type entry struct {
+		Name, Title, Author, Date string
+		Slides                    int
+		Error                     string
+	}
+	var data struct {
+		Title   string
+		Palette render.Palette
+		Decks   []entry
+	}
+	data.Title = filepath.Base(l.dir)
+	data.Palette = render.NewPalette(theme.Theme{})
+	if config, err := theme.LoadConfig(l.cfgPath); err == nil {
+		data.Palette = render.NewPalette(config.Themes[l.theme])
+	}
+
+	l.mu.RLock()
+	for name, d := range l.decks {
+		e := entry{Name: name, Title: name}
+		if d.err != nil {
+			e.Error = d.err.Error()
+		}
+		if d.srv != nil {
+			deck := d.srv.current()
+			if strings.TrimSpace(deck.Frontmatter.Title) != "" {
+				e.Title = deck.Frontmatter.Title
+			}
+			e.Author, e.Date, e.Slides = deck.Author, deck.Date, len(deck.Slides)
+		}
+		data.Decks = append(data.Decks, e)
+	}
+	l.mu.RUnlock()
+	sort.Slice(data.Decks, func(i, j int) bool { return data.Decks[i].Name < data.Decks[j].Name })
+
+	if err := indexTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
+            margin: 0;
+            padding: 40px;
+            background: {{.Palette.Background}};
+            color: {{.Palette.Foreground}};
+        }
+        h1 { color: {{.Palette.Heading}}; }
+        table { border-collapse: collapse; width: 100%; max-width: 960px; }
+        th, td { text-align: left; padding: 10px 16px; border-bottom: 1px solid {{.Palette.Border}}; }
+        th { background: {{.Palette.TableHead}}; }
+        td.slides { text-align: right; }
+        a { color: {{.Palette.Link}}; }
+        .name, .error, .snippet { opacity: 0.7; font-size: 0.85em; }
+        input {
+            font: inherit;
+            width: 100%;
+            max-width: 480px;
+            padding: 8px 10px;
+            margin-bottom: 16px;
+            color: inherit;
+            background: transparent;
+            border: 1px solid {{.Palette.Border}};
+            border-radius: 4px;
+        }
+        #results { list-style: none; padding: 0; max-width: 960px; }
+        #results li { margin-bottom: 12px; }
+    </style>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    <input id="search" type="search" placeholder="Search all decks" autocomplete="off" aria-label="Search all decks">
+    <ol id="results"></ol>
+    {{if .Decks}}
+    <table>
+        <thead><tr><th>Deck</th><th>Author</th><th>Date</th><th>Slides</th></tr></thead>
+        <tbody>
+        {{range .Decks}}
+            <tr>
+                <td>
+                    <a href="/deck/{{.Name}}/">{{.Title}}</a>
+                    {{if ne .Title .Name}}<div class="name">{{.Name}}</div>{{end}}
+                    {{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+                </td>
+                <td>{{.Author}}</td>
+                <td>{{.Date}}</td>
+                <td class="slides">{{if .Slides}}{{.Slides}}{{end}}</td>
+            </tr>
+        {{end}}
+        </tbody>
+    </table>
+    {{else}}
+    <p>No decks found.</p>
+    {{end}}
+    <script>
+        // Search every deck's slides, code and notes through /api/search
+        const input = document.getElementById('search');
+        let timer = null;
+        input.addEventListener('input', function() {
+            clearTimeout(timer);
+            timer = setTimeout(function() {
+                const query = input.value;
+                const list = document.getElementById('results');
+                if (!query.trim()) {
+                    list.innerHTML = '';
+                    return;
+                }
+                fetch('/api/search?q=' + encodeURIComponent(query))
+                    .then(response => response.json())
+                    .then(results => {
+                        if (input.value !== query) {
+                            return;
+                        }
+                        list.innerHTML = '';
+                        results.forEach(result => {
+                            const item = document.createElement('li');
+                            const link = document.createElement('a');
+                            link.href = result.url;
+                            link.textContent = result.deck + ' · ' + result.label + (result.title ? ' · ' + result.title : '') + (result.in_notes ? ' (notes)' : '');
+                            const snippet = document.createElement('div');
+                            snippet.className = 'snippet';
+                            snippet.textContent = result.snippet;
+                            item.appendChild(link);
+                            item.appendChild(snippet);
+                            list.appendChild(item);
+                        });
+                    });
+            }, 150);
+        });
+    </script>
+</body>
+</html>
+`))
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	"slides/deck"
+)
+
+// imageOnly returns the image a paragraph consists of, if it holds nothing
+// else. Exporters lay such paragraphs out as pictures.
+func imageOnly(p *deck.Paragraph) *deck.Image {
+	if len(p.Inlines) != 1 {
+		return nil
+	}
+	img, _ := p.Inlines[0].(*deck.Image)
+	return img
+}
+
+// textRun is a piece of inline text sharing one style, for output formats
+// that lay text out themselves.
+type textRun struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Code   bool
+	Link   bool
+	URL    string // link target
+}
+
+// flattenInlines turns an inline tree into styled runs. Line breaks become
+// spaces and images are represented by their alt text.
+func flattenInlines(inlines []deck.Inline) []textRun {
+	var runs []textRun
+	var walk func([]deck.Inline, textRun)
+	walk = func(nodes []deck.Inline, style textRun) {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case *deck.Text:
+				run := style
+				run.Text = n.Value
+				runs = append(runs, run)
+			case *deck.SoftBreak, *deck.HardBreak:
+				run := style
+				run.Text = " "
+				runs = append(runs, run)
+			case *deck.CodeSpan:
+				run := style
+				run.Text, run.Code = n.Code, true
+				runs = append(runs, run)
+			case *deck.Emphasis:
+				s := style
+				s.Italic = true
+				walk(n.Children, s)
+			case *deck.Strong:
+				s := style
+				s.Bold = true
+				walk(n.Children, s)
+			case *deck.Link:
+				s := style
+				s.Link, s.URL = true, n.Dest
+				walk(n.Children, s)
+			case *deck.Image:
+				run := style
+				run.Text = "[" + deck.PlainText(n.Children) + "]"
+				runs = append(runs, run)
+			}
+		}
+	}
+	walk(inlines, textRun{})
+	return runs
+}
+
+// columnWidths splits total between columns as a CSS grid would: percentages
+// of total first, then the rest by fr share. gap is left between columns.
+func columnWidths(cols []*deck.Column, total, gap float64) []float64 {
+	widths := make([]float64, len(cols))
+	free := total - gap*float64(len(cols)-1)
+	var shares float64
+	for i, col := range cols {
+		if pct, ok := strings.CutSuffix(col.Width, "%"); ok {
+			v, _ := strconv.ParseFloat(pct, 64)
+			widths[i] = total * v / 100
+			free -= widths[i]
+			continue
+		}
+		v, _ := strconv.ParseFloat(strings.TrimSuffix(col.Width, "fr"), 64)
+		shares += v
+	}
+	for i, col := range cols {
+		if !strings.HasSuffix(col.Width, "%") && shares > 0 && free > 0 {
+			v, _ := strconv.ParseFloat(strings.TrimSuffix(col.Width, "fr"), 64)
+			widths[i] = free * v / shares
+		}
+	}
+	return widths
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"slides/deck"
+	"slides/theme"
+)
+
+// Palette holds the handful of colors the non-HTML exporters need. They are
+// read out of the theme CSS, so themes don't have to declare them twice.
+type Palette struct {
+	Background string
+	Foreground string
+	Heading    string
+	CodeBg     string
+	CodeFg     string
+	Link       string
+	Border     string            // table grid
+	TableHead  string            // table header background
+	Syntax     map[string]string // token kind → code color
+}
+
+var (
+	cssRuleRegex  = regexp.MustCompile(`([^{}]+)\{([^}]*)\}`)
+	cssColorRegex = regexp.MustCompile(`#(?:[0-9a-fA-F]{6}|[0-9a-fA-F]{3})\b`)
+)
+
+// NewPalette extracts colors from the theme CSS, falling back to black on
+// white for anything the theme doesn't set.
+func NewPalette(t theme.Theme) Palette {
+	rules := parseCSSRules(t.CSS)
+	pick := func(prop string, selectors ...string) string {
+		for _, sel := range selectors {
+			if c := rules[sel][prop]; c != "" {
+				return c
+			}
+		}
+		return ""
+	}
+
+	p := Palette{
+		Background: pick("background", ".slide", "body"),
+		Foreground: pick("color", ".slide", "body"),
+		Heading:    pick("color", "h1", "h2"),
+		CodeBg:     pick("background", "pre", "code"),
+		CodeFg:     pick("color", "pre code", "pre", "code"),
+		Link:       pick("color", "a"),
+		Border:     pick("border", "td", "th", "pre"),
+		TableHead:  pick("background", "th"),
+	}
+	if p.Background == "" {
+		p.Background = "#ffffff"
+	}
+	if p.Foreground == "" {
+		p.Foreground = "#000000"
+	}
+	if p.Heading == "" {
+		p.Heading = p.Foreground
+	}
+	if p.CodeBg == "" {
+		p.CodeBg = p.Background
+	}
+	if p.CodeFg == "" {
+		p.CodeFg = p.Foreground
+	}
+	if p.Link == "" {
+		p.Link = p.Foreground
+	}
+	if p.Border == "" {
+		p.Border = p.Foreground
+	}
+	if p.TableHead == "" {
+		p.TableHead = p.Background
+	}
+	p.Syntax = make(map[string]string)
+	for kind, color := range t.Syntax {
+		if c := cssColorRegex.FindString(color); c != "" {
+			p.Syntax[kind] = c
+		}
+	}
+	return p
+}
+
+// tokenColor is the color for a highlighted code token.
+func (p Palette) tokenColor(kind string) string {
+	if c := p.Syntax[kind]; c != "" {
+		return c
+	}
+	return p.CodeFg
+}
+
+// slideBackground is the slide's background directive when it is a hex
+// color, and the theme background otherwise. Other colors and images are
+// left to the browser.
+func (p Palette) slideBackground(slide deck.Slide) string {
+	if slide.Background != "" && cssColorRegex.FindString(slide.Background) == slide.Background {
+		return slide.Background
+	}
+	return p.Background
+}
+
+// parseCSSRules maps each selector to the hex colors of its "background",
+// "color" and "border" properties. Only what NewPalette needs is understood.
+func parseCSSRules(css string) map[string]map[string]string {
+	rules := make(map[string]map[string]string)
+	for _, m := range cssRuleRegex.FindAllStringSubmatch(css, -1) {
+		props := make(map[string]string)
+		for _, decl := range strings.Split(m[2], ";") {
+			name, value, ok := strings.Cut(decl, ":")
+			if !ok {
+				continue
+			}
+			name = strings.TrimSpace(name)
+			switch name {
+			case "background-color":
+				name = "background"
+			case "border-color":
+				name = "border"
+			case "background", "color", "border":
+			default:
+				continue
+			}
+			if c := cssColorRegex.FindString(value); c != "" {
+				props[name] = c
+			}
+		}
+		for _, sel := range strings.Split(m[1], ",") {
+			sel = strings.Join(strings.Fields(sel), " ")
+			if rules[sel] == nil {
+				rules[sel] = make(map[string]string)
+			}
+			for k, v := range props {
+				rules[sel][k] = v
+			}
+		}
+	}
+	return rules
+}
+
+// hexRGB converts #rgb or #rrggbb into 0–1 components.
+func hexRGB(hex string) (r, g, b float64) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255
+}
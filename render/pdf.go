@@ -0,0 +1,729 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"slides/deck"
+	"slides/highlight"
+)
+
+// pdfPageSizes are landscape page sizes in points.
+var pdfPageSizes = map[string][2]float64{
+	"16:9":   {960, 540},
+	"4:3":    {720, 540},
+	"a4":     {842, 595},
+	"letter": {792, 612},
+}
+
+// ParsePageSize accepts a named size from pdfPageSizes or "WIDTHxHEIGHT" in points.
+func ParsePageSize(s string) (float64, float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if size, ok := pdfPageSizes[s]; ok {
+		return size[0], size[1], nil
+	}
+	ws, hs, ok := strings.Cut(s, "x")
+	if ok {
+		w, werr := strconv.ParseFloat(ws, 64)
+		h, herr := strconv.ParseFloat(hs, 64)
+		if werr == nil && herr == nil && w > 0 && h > 0 {
+			return w, h, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unknown page size %q (use 16:9, 4:3, a4, letter or WIDTHxHEIGHT in points)", s)
+}
+
+// PDFOptions controls the page geometry of a PDF export.
+type PDFOptions struct {
+	Width, Height float64 // points
+	Margin        float64 // points
+}
+
+// PDF lays out every slide on its own landscape page, drawing headings,
+// paragraphs, lists, code blocks and images with the theme colors, plus the
+// classification banner and watermark. It only uses the 14 standard PDF fonts,
+// so characters outside Windows-1252 (such as emoji) are dropped.
+func PDF(w io.Writer, d *deck.Deck, assetDir string, opts PDFOptions) error {
+	doc := &pdfDoc{}
+	pagesID := doc.reserve()
+	resourcesID := doc.reserve()
+
+	l := &pdfLayout{
+		doc:      doc,
+		deck:     d,
+		assetDir: assetDir,
+		opts:     opts,
+		pal:      NewPalette(d.Theme),
+		scale:    opts.Height / 540,
+		images:   make(map[string]*pdfImage),
+	}
+
+	var pageIDs []int
+	for _, slide := range d.Slides {
+		content := l.renderPage(slide, d.Sections())
+		contentID := doc.add(doc.stream("", content))
+		pageIDs = append(pageIDs, doc.add([]byte(fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+			pagesID, num(opts.Width), num(opts.Height), resourcesID, contentID))))
+	}
+
+	kids := make([]string, len(pageIDs))
+	for i, id := range pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	doc.set(pagesID, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pageIDs))))
+
+	// One shared resource dictionary: the standard fonts, watermark
+	// transparency and every image used anywhere in the deck
+	var res strings.Builder
+	res.WriteString("<< /Font <<")
+	for _, f := range pdfFonts {
+		fontID := doc.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.base)))
+		fmt.Fprintf(&res, " /%s %d 0 R", f.name, fontID)
+	}
+	res.WriteString(" >>")
+	gsID := doc.add([]byte(fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s >>", num(l.watermarkOpacity()), num(l.watermarkOpacity()))))
+	fmt.Fprintf(&res, " /ExtGState << /GSW %d 0 R >>", gsID)
+	if len(l.images) > 0 {
+		res.WriteString(" /XObject <<")
+		for _, img := range l.images {
+			if img != nil {
+				fmt.Fprintf(&res, " /%s %d 0 R", img.name, img.id)
+			}
+		}
+		res.WriteString(" >>")
+	}
+	res.WriteString(" >>")
+	doc.set(resourcesID, []byte(res.String()))
+
+	author := ""
+	if d.Author != "" {
+		author = " /Author " + pdfString(d.Author)
+	}
+	info := doc.add([]byte(fmt.Sprintf("<< /Title %s%s /Producer (slides.md) >>", pdfString(d.Title), author)))
+	catalog := doc.add([]byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID)))
+	return doc.writeTo(w, catalog, info)
+}
+
+// pdfDoc collects numbered objects and serializes them with a cross-reference table.
+type pdfDoc struct {
+	objects [][]byte
+}
+
+func (d *pdfDoc) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+func (d *pdfDoc) add(body []byte) int {
+	d.objects = append(d.objects, body)
+	return len(d.objects)
+}
+
+func (d *pdfDoc) set(id int, body []byte) {
+	d.objects[id-1] = body
+}
+
+// stream wraps data in a stream object; extra is added to its dictionary.
+func (d *pdfDoc) stream(extra string, data []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", extra, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+func (d *pdfDoc) writeTo(w io.Writer, root, info int) error {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, obj := range d.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(obj)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, root, info, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pdfFont is one of the standard Type 1 fonts every PDF reader ships.
+type pdfFont struct {
+	name   string
+	base   string
+	widths *[95]int // glyph widths for ' '..'~' in 1/1000 em; nil for monospace
+}
+
+var (
+	fontRegular    = &pdfFont{"F1", "Helvetica", &helveticaWidths}
+	fontBold       = &pdfFont{"F2", "Helvetica-Bold", &helveticaBoldWidths}
+	fontItalic     = &pdfFont{"F3", "Helvetica-Oblique", &helveticaWidths}
+	fontBoldItalic = &pdfFont{"F4", "Helvetica-BoldOblique", &helveticaBoldWidths}
+	fontMono       = &pdfFont{"F5", "Courier", nil}
+	pdfFonts       = []*pdfFont{fontRegular, fontBold, fontItalic, fontBoldItalic, fontMono}
+)
+
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// measure returns the width of the WinAnsi-encoded text at the given size.
+func (f *pdfFont) measure(text []byte, size float64) float64 {
+	if f.widths == nil {
+		return float64(len(text)) * 0.6 * size
+	}
+	total := 0
+	for _, c := range text {
+		switch {
+		case c >= 32 && c <= 126:
+			total += f.widths[c-32]
+		case c == 0x95: // bullet
+			total += 350
+		case c == 0x97: // em dash
+			total += 1000
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// winAnsiSpecials maps the Unicode characters that Windows-1252 places in
+// 0x80–0x9F.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi encodes s for the standard fonts, dropping what they can't show.
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, "    "...)
+		case r >= 32 && r <= 126, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiSpecials[r]; ok {
+				out = append(out, b)
+			}
+		}
+	}
+	return out
+}
+
+// pdfString writes text as a PDF literal string.
+func pdfString(s string) string {
+	return pdfLiteral(winAnsi(s))
+}
+
+func pdfLiteral(b []byte) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(c)
+	}
+	out.WriteByte(')')
+	return out.String()
+}
+
+// num formats a coordinate compactly.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// pdfImage is an image XObject shared by every page that shows it.
+type pdfImage struct {
+	name          string
+	id            int
+	width, height int
+}
+
+// pdfLayout draws slides top to bottom. y is measured down from the top edge.
+type pdfLayout struct {
+	doc      *pdfDoc
+	deck     *deck.Deck
+	assetDir string
+	opts     PDFOptions
+	pal      Palette
+	scale    float64
+	images   map[string]*pdfImage // by source; nil when the image can't be embedded
+
+	out *bytes.Buffer
+	y   float64
+}
+
+func (l *pdfLayout) renderPage(slide deck.Slide, sections int) []byte {
+	l.out = &bytes.Buffer{}
+	w, h, m := l.opts.Width, l.opts.Height, l.opts.Margin
+
+	l.fillRect(0, 0, w, h, l.pal.slideBackground(slide))
+
+	// Header: deck title, classification banner and slide counter
+	small := 11 * l.scale
+	l.text(m, m*0.5+small, fontRegular, small, l.pal.Foreground, winAnsi(l.deck.Title))
+	counter := winAnsi(fmt.Sprintf("%s / %d", slide.Label(), sections))
+	l.text(w-m-fontRegular.measure(counter, small), m*0.5+small, fontRegular, small, l.pal.Foreground, counter)
+	if label := strings.TrimSpace(l.deck.Theme.ClassificationLabel); label != "" {
+		bg, fg := classificationColors(l.deck.Theme)
+		text := winAnsi(label)
+		tw := fontBold.measure(text, small)
+		pad := 8 * l.scale
+		l.fillRect(w/2-tw/2-pad, m*0.5-small*0.3, tw+2*pad, small*1.7, bg)
+		l.text(w/2-tw/2, m*0.5+small, fontBold, small, fg, text)
+	}
+
+	l.y = m + 28*l.scale
+	l.blocks(slide.Node.Blocks, m, w-2*m)
+
+	if l.deck.Theme.Watermark {
+		l.watermark()
+	}
+	return l.out.Bytes()
+}
+
+// blocks lays out blocks in the column starting at x, stopping at the
+// bottom margin.
+func (l *pdfLayout) blocks(blocks []deck.Block, x, maxWidth float64) {
+	for _, b := range blocks {
+		if l.y >= l.opts.Height-l.opts.Margin {
+			return
+		}
+		l.block(b, x, maxWidth)
+	}
+}
+
+func (l *pdfLayout) block(b deck.Block, x, maxWidth float64) {
+	body := 18 * l.scale
+
+	switch b := b.(type) {
+	case *deck.Heading:
+		sizes := []float64{36, 30, 24, 20, 18, 16}
+		size := sizes[b.Level-1] * l.scale
+		l.paragraph(flattenInlines(b.Inlines), x, maxWidth, size, true, l.pal.Heading)
+		l.y += size * 0.4
+	case *deck.Paragraph:
+		if img := imageOnly(b); img != nil {
+			l.image(img, x, maxWidth)
+			return
+		}
+		l.paragraph(flattenInlines(b.Inlines), x, maxWidth, body, false, l.pal.Foreground)
+		l.y += body * 0.6
+	case *deck.List:
+		indent := 28 * l.scale
+		for i, item := range b.Items {
+			marker := winAnsi("•")
+			if b.Ordered {
+				marker = winAnsi(fmt.Sprintf("%d.", b.Start+i))
+			}
+			l.text(x+indent*0.3, l.y+body, fontRegular, body, l.pal.Foreground, marker)
+			l.listItem(item, b.Tight, x+indent, maxWidth-indent)
+		}
+		l.y += body * 0.4
+	case *deck.BlockQuote:
+		indent := 20 * l.scale
+		top := l.y
+		l.blocks(b.Blocks, x+indent, maxWidth-indent)
+		l.fillRect(x, top+body*0.3, 3*l.scale, l.y-top-body*0.6, l.pal.Foreground)
+	case *deck.CodeBlock:
+		l.code(b, x, maxWidth)
+	case *deck.Table:
+		l.table(b, x, maxWidth)
+	case *deck.Columns:
+		l.columns(b, x, maxWidth)
+	case *deck.ThematicBreak:
+		r, g, bl := hexRGB(l.pal.Foreground)
+		fmt.Fprintf(l.out, "%s %s %s RG 1 w %s %s m %s %s l S\n", num(r), num(g), num(bl),
+			num(x), num(l.opts.Height-l.y-body/2), num(x+maxWidth), num(l.opts.Height-l.y-body/2))
+		l.y += body
+	}
+}
+
+// columns lays out columns side by side and continues below the tallest.
+func (l *pdfLayout) columns(b *deck.Columns, x, maxWidth float64) {
+	gap := 24 * l.scale
+	top, bottom := l.y, l.y
+	for i, w := range columnWidths(b.Columns, maxWidth, gap) {
+		if w > 0 {
+			l.y = top
+			l.blocks(b.Columns[i].Blocks, x, w)
+			bottom = math.Max(bottom, l.y)
+		}
+		x += w + gap
+	}
+	l.y = bottom
+}
+
+// listItem lays out the blocks of one list item. Paragraphs of tight lists
+// are spaced like lines rather than paragraphs.
+func (l *pdfLayout) listItem(item *deck.ListItem, tight bool, x, maxWidth float64) {
+	body := 18 * l.scale
+	if len(item.Blocks) == 0 {
+		l.y += body * 1.55
+		return
+	}
+	for _, b := range item.Blocks {
+		if p, ok := b.(*deck.Paragraph); ok && tight && imageOnly(p) == nil {
+			l.paragraph(flattenInlines(p.Inlines), x, maxWidth, body, false, l.pal.Foreground)
+			l.y += body * 0.25
+			continue
+		}
+		l.block(b, x, maxWidth)
+	}
+}
+
+// paragraph word-wraps styled runs into the box starting at x.
+func (l *pdfLayout) paragraph(runs []textRun, x, maxWidth, size float64, heading bool, color string) {
+	type word struct {
+		text        []byte
+		font        *pdfFont
+		color       string
+		spaceBefore bool
+	}
+
+	var words []word
+	pendingSpace := false
+	for _, run := range runs {
+		font := fontRegular
+		switch {
+		case run.Code:
+			font = fontMono
+		case (run.Bold || heading) && run.Italic:
+			font = fontBoldItalic
+		case run.Bold || heading:
+			font = fontBold
+		case run.Italic:
+			font = fontItalic
+		}
+		c := color
+		if run.Link {
+			c = l.pal.Link
+		}
+		start := -1
+		for i, r := range run.Text + " " {
+			if r != ' ' && r != '\t' {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				words = append(words, word{winAnsi(run.Text[start:i]), font, c, pendingSpace && len(words) > 0})
+				pendingSpace = false
+				start = -1
+			}
+			if i < len(run.Text) {
+				pendingSpace = true
+			}
+		}
+	}
+
+	lineHeight := size * 1.3
+	cx := x
+	l.y += lineHeight
+	for _, wd := range words {
+		ww := wd.font.measure(wd.text, size)
+		if wd.spaceBefore {
+			cx += fontRegular.measure([]byte(" "), size)
+		}
+		if cx > x && cx+ww > x+maxWidth {
+			cx = x
+			l.y += lineHeight
+		}
+		l.text(cx, l.y-size*0.25, wd.font, size, wd.color, wd.text)
+		cx += ww
+	}
+}
+
+// table draws a grid of equally wide columns. Cells wrap their text and a
+// row is as tall as its tallest cell.
+func (l *pdfLayout) table(t *deck.Table, x, maxWidth float64) {
+	size := 16 * l.scale
+	pad := 6 * l.scale
+	colWidth := maxWidth / float64(len(t.Align))
+	r, g, b := hexRGB(l.pal.Border)
+
+	rows := append([][]*deck.TableCell{t.Header}, t.Rows...)
+	for i, row := range rows {
+		if l.y >= l.opts.Height-l.opts.Margin {
+			break
+		}
+		// Lay the cells out first so the header fill can go underneath
+		top, bottom := l.y, l.y
+		page := l.out
+		l.out = &bytes.Buffer{}
+		for c, cell := range row {
+			l.y = top + pad*0.5
+			l.paragraph(flattenInlines(cell.Inlines), x+float64(c)*colWidth+pad, colWidth-2*pad, size, i == 0, l.pal.Foreground)
+			bottom = math.Max(bottom, l.y+pad)
+		}
+		cells := l.out
+		l.out = page
+
+		if i == 0 {
+			l.fillRect(x, top, maxWidth, bottom-top, l.pal.TableHead)
+		}
+		l.out.Write(cells.Bytes())
+		fmt.Fprintf(l.out, "%s %s %s RG 0.75 w", num(r), num(g), num(b))
+		for c := range row {
+			fmt.Fprintf(l.out, " %s %s %s %s re", num(x+float64(c)*colWidth), num(l.opts.Height-bottom), num(colWidth), num(bottom-top))
+		}
+		l.out.WriteString(" S\n")
+		l.y = bottom
+	}
+	l.y += size
+}
+
+// code draws a highlighted code block, breaking long lines at the box edge.
+func (l *pdfLayout) code(b *deck.CodeBlock, x, maxWidth float64) {
+	size := 14 * l.scale
+	lineHeight := size * 1.35
+	pad := 12 * l.scale
+	perLine := int((maxWidth - 2*pad) / (0.6 * size))
+	if perLine < 1 {
+		perLine = 1
+	}
+
+	type segment struct {
+		color string
+		text  []byte
+	}
+	// Handouts show the first step of a {1|3-4|6} attribute
+	marked := map[int]bool{}
+	if len(b.Steps) > 0 {
+		for _, n := range b.Steps[0] {
+			marked[n] = true
+		}
+	}
+	var lines [][]segment
+	var bands []bool
+	for i, tokens := range highlight.Lines(highlight.Tokens(strings.TrimSuffix(b.Code, "\n"), b.Lang)) {
+		var line []segment
+		width := 0
+		for _, t := range tokens {
+			enc := winAnsi(t.Text)
+			for len(enc) > 0 {
+				if width == perLine {
+					lines = append(lines, line)
+					bands = append(bands, marked[i+1])
+					line, width = nil, 0
+				}
+				n := min(len(enc), perLine-width)
+				line = append(line, segment{l.pal.tokenColor(t.Kind), enc[:n]})
+				width += n
+				enc = enc[n:]
+			}
+		}
+		lines = append(lines, line)
+		bands = append(bands, marked[i+1])
+	}
+	// Don't run off the page
+	if avail := int((l.opts.Height - l.opts.Margin - l.y - 2*pad) / lineHeight); len(lines) > avail && avail >= 0 {
+		lines = lines[:avail]
+	}
+
+	height := float64(len(lines))*lineHeight + 2*pad
+	l.fillRect(x, l.y, maxWidth, height, l.pal.CodeBg)
+	for i, line := range lines {
+		if bands[i] {
+			l.fillRect(x, l.y+pad+float64(i)*lineHeight+size*0.1, maxWidth, lineHeight, l.pal.Border)
+		}
+		cx := x + pad
+		for _, seg := range line {
+			l.text(cx, l.y+pad+float64(i+1)*lineHeight-size*0.3, fontMono, size, seg.color, seg.text)
+			cx += fontMono.measure(seg.text, size)
+		}
+	}
+	l.y += height + size
+}
+
+func (l *pdfLayout) image(b *deck.Image, x, maxWidth float64) {
+	img := l.loadImage(b.Src)
+	if img == nil {
+		// SVG, WebP or remote images can't be embedded without a browser
+		l.paragraph([]textRun{{Text: "[" + deck.PlainText(b.Children) + "]", Italic: true}}, x, maxWidth, 18*l.scale, false, l.pal.Foreground)
+		l.y += 18 * l.scale * 0.6
+		return
+	}
+
+	maxHeight := l.opts.Height - l.opts.Margin - l.y
+	w, h := float64(img.width), float64(img.height)
+	fit := math.Min(1, math.Min(maxWidth/w, maxHeight/h))
+	w, h = w*fit, h*fit
+	if w <= 0 || h <= 0 {
+		return
+	}
+	fmt.Fprintf(l.out, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(l.opts.Height-l.y-h), img.name)
+	l.y += h + 12*l.scale
+}
+
+// loadImage embeds a local JPEG, PNG or GIF once and returns its XObject.
+func (l *pdfLayout) loadImage(src string) *pdfImage {
+	if img, seen := l.images[src]; seen {
+		return img
+	}
+	l.images[src] = nil
+
+	lower := strings.ToLower(src)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "data:") {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(l.assetDir, filepath.FromSlash(strings.TrimPrefix(src, "/"))))
+	if err != nil {
+		return nil
+	}
+
+	name := fmt.Sprintf("Im%d", len(l.images))
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && format == "jpeg" {
+		// JPEG data can be embedded as is
+		colorSpace := "DeviceRGB"
+		switch cfg.ColorModel {
+		case colorModelGray:
+			colorSpace = "DeviceGray"
+		case colorModelCMYK:
+			colorSpace = "DeviceCMYK"
+		}
+		id := l.doc.add(l.doc.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode",
+			cfg.Width, cfg.Height, colorSpace), data))
+		l.images[src] = &pdfImage{name, id, cfg.Width, cfg.Height}
+		return l.images[src]
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	bounds := decoded.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := decoded.At(x, y).RGBA()
+			// Undo premultiplication so transparent edges keep their color
+			if a > 0 && a < 0xffff {
+				r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+			}
+			rgb = append(rgb, byte(r>>8), byte(g>>8), byte(b>>8))
+			alpha = append(alpha, byte(a>>8))
+			if a != 0xffff {
+				opaque = false
+			}
+		}
+	}
+
+	extra := ""
+	if !opaque {
+		maskID := l.doc.add(l.doc.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode",
+			bounds.Dx(), bounds.Dy()), deflate(alpha)))
+		extra = fmt.Sprintf(" /SMask %d 0 R", maskID)
+	}
+	id := l.doc.add(l.doc.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode%s",
+		bounds.Dx(), bounds.Dy(), extra), deflate(rgb)))
+	l.images[src] = &pdfImage{name, id, bounds.Dx(), bounds.Dy()}
+	return l.images[src]
+}
+
+var (
+	colorModelGray = (&image.Gray{}).ColorModel()
+	colorModelCMYK = (&image.CMYK{}).ColorModel()
+)
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	return b.Bytes()
+}
+
+// watermark tiles the watermark text diagonally across the page, like the
+// HTML overlay.
+func (l *pdfLayout) watermark() {
+	text := winAnsi(watermarkText(l.deck.Theme, l.deck.Title))
+	size := 36 * l.scale
+	tw := fontBold.measure(text, size)
+	step := tw + 80*l.scale
+	r, g, b := hexRGB(l.pal.Foreground)
+	angle := 25 * math.Pi / 180
+	cos, sin := math.Cos(angle), math.Sin(angle)
+
+	fmt.Fprintf(l.out, "q /GSW gs %s %s %s rg\n", num(r), num(g), num(b))
+	diag := math.Hypot(l.opts.Width, l.opts.Height)
+	for row := -diag; row < diag; row += 120 * l.scale {
+		offset := math.Mod(row, step*2) / 2
+		for col := -diag - offset; col < diag; col += step {
+			// Rotate the (col, row) grid around the page center
+			x := l.opts.Width/2 + col*cos - row*sin
+			y := l.opts.Height/2 + col*sin + row*cos
+			if x > l.opts.Width || y > l.opts.Height || x+tw*cos < 0 || y+tw*sin < 0 {
+				continue
+			}
+			fmt.Fprintf(l.out, "BT /%s %s Tf %s %s %s %s %s %s Tm %s Tj ET\n", fontBold.name, num(size),
+				num(cos), num(sin), num(-sin), num(cos), num(x), num(y), pdfLiteral(text))
+		}
+	}
+	l.out.WriteString("Q\n")
+}
+
+func (l *pdfLayout) watermarkOpacity() float64 {
+	op := l.deck.Theme.WatermarkOpacity
+	if op <= 0 || op > 1 {
+		op = 0.08
+	}
+	return op
+}
+
+// text draws a single line with its baseline y points below the top edge.
+func (l *pdfLayout) text(x, y float64, font *pdfFont, size float64, color string, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+	r, g, b := hexRGB(color)
+	fmt.Fprintf(l.out, "BT /%s %s Tf %s %s %s rg %s %s Td %s Tj ET\n", font.name, num(size),
+		num(r), num(g), num(b), num(x), num(l.opts.Height-y), pdfLiteral(text))
+}
+
+// fillRect fills a rectangle whose top-left corner is y points below the top edge.
+func (l *pdfLayout) fillRect(x, y, w, h float64, color string) {
+	r, g, b := hexRGB(color)
+	fmt.Fprintf(l.out, "%s %s %s rg %s %s %s %s re f\n", num(r), num(g), num(b),
+		num(x), num(l.opts.Height-y-h), num(w), num(h))
+}
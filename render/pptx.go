@@ -0,0 +1,612 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"slides/deck"
+	"slides/highlight"
+)
+
+// PPTX geometry in EMU (914400 per inch, 12700 per point) for a 16:9 deck.
+const (
+	pptxWidth   = 12192000
+	pptxHeight  = 6858000
+	pptxMargin  = 457200
+	emuPerPoint = 12700
+	emuPerPixel = 9525 // at 96 dpi
+)
+
+const (
+	nsA = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"`
+	nsR = `xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	nsP = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+
+	relBase = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+)
+
+// PPTX writes the deck as an Office Open XML presentation. Headings
+// become slide titles, lists native bullets, code blocks monospace text boxes
+// and local images embedded media; speaker notes go to the notes pane.
+func PPTX(w io.Writer, d *deck.Deck, assetDir string) error {
+	p := &pptxWriter{
+		zip:      zip.NewWriter(w),
+		deck:     d,
+		assetDir: assetDir,
+		pal:      NewPalette(d.Theme),
+		media:    make(map[string]*pptxMedia),
+	}
+
+	for i, slide := range d.Slides {
+		if err := p.slide(i+1, slide); err != nil {
+			return err
+		}
+	}
+	if err := p.packageParts(); err != nil {
+		return err
+	}
+	return p.zip.Close()
+}
+
+type pptxWriter struct {
+	zip      *zip.Writer
+	deck     *deck.Deck
+	assetDir string
+	pal      Palette
+	media    map[string]*pptxMedia // by image source; nil when it can't be embedded
+	mediaSeq int
+	notes    []int // slide numbers that have a notes page
+}
+
+// pptxMedia is an image stored once under ppt/media.
+type pptxMedia struct {
+	part          string
+	width, height int // pixels
+}
+
+// pptxRels collects the relationships of a single part.
+type pptxRels struct {
+	entries []string
+}
+
+func (r *pptxRels) add(typ, target string, external bool) string {
+	id := fmt.Sprintf("rId%d", len(r.entries)+1)
+	mode := ""
+	if external {
+		mode = ` TargetMode="External"`
+	}
+	r.entries = append(r.entries, fmt.Sprintf(`<Relationship Id="%s" Type="%s%s" Target="%s"%s/>`, id, relBase, typ, xmlText(target), mode))
+	return id
+}
+
+func (r *pptxRels) xml() string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		strings.Join(r.entries, "") + `</Relationships>`
+}
+
+func (p *pptxWriter) write(name, content string) error {
+	f, err := p.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+// xmlText escapes s for use in XML text and attribute values.
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// pptxColor turns a CSS hex color into the RRGGBB form DrawingML expects.
+func pptxColor(hex string) string {
+	r, g, b := hexRGB(hex)
+	return fmt.Sprintf("%02X%02X%02X", int(r*255+0.5), int(g*255+0.5), int(b*255+0.5))
+}
+
+// slide writes one slide, its relationships and its notes page.
+func (p *pptxWriter) slide(number int, slide deck.Slide) error {
+	rels := &pptxRels{}
+	rels.add("slideLayout", "../slideLayouts/slideLayout1.xml", false)
+
+	blocks := slide.Node.Blocks
+	var shapes []string
+	shapeID := 2
+	nextID := func() int { shapeID++; return shapeID - 1 }
+
+	// The first heading is the slide title
+	top := int64(pptxMargin)
+	if len(blocks) > 0 {
+		if h, ok := blocks[0].(*deck.Heading); ok {
+			size := 3600
+			if h.Level > 1 {
+				size = 3200
+			}
+			height := int64(size) * emuPerPoint / 100 * 2
+			shapes = append(shapes, fmt.Sprintf(`<p:sp><p:nvSpPr><p:cNvPr id="%d" name="Title"/><p:cNvSpPr><a:spLocks noGrp="1"/></p:cNvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr>%s<p:txBody><a:bodyPr anchor="b"><a:normAutofit/></a:bodyPr><a:lstStyle/><a:p>%s</a:p></p:txBody></p:sp>`,
+				nextID(), pptxXfrm(pptxMargin, top, pptxWidth-2*pptxMargin, height), p.runs(rels, flattenInlines(h.Inlines), size, true, p.pal.Heading)))
+			top += height + pptxMargin/2
+			blocks = blocks[1:]
+		}
+	}
+
+	// Everything else flows down the slide, or down a column's box. Runs of
+	// text blocks share one text box; code blocks and images get their own
+	// shapes.
+	var paras []string
+	var textHeight int64
+	boxX, boxW := int64(pptxMargin), int64(pptxWidth-2*pptxMargin)
+	flushText := func() {
+		if len(paras) == 0 {
+			return
+		}
+		shapes = append(shapes, fmt.Sprintf(`<p:sp><p:nvSpPr><p:cNvPr id="%d" name="Content"/><p:cNvSpPr txBox="1"/><p:nvPr/></p:nvSpPr>%s<p:txBody><a:bodyPr wrap="square"><a:normAutofit/></a:bodyPr><a:lstStyle/>%s</p:txBody></p:sp>`,
+			nextID(), pptxXfrm(boxX, top, boxW, textHeight), strings.Join(paras, "")))
+		top += textHeight
+		paras, textHeight = nil, 0
+	}
+
+	// walk lays out blocks indented by marL. Nested lists and block quotes
+	// indent further; list markers are passed down to the first paragraph
+	// of each item.
+	var walk func(blocks []deck.Block, marL int64, level int)
+	walk = func(blocks []deck.Block, marL int64, level int) {
+		width := boxW - marL
+		for _, block := range blocks {
+			switch b := block.(type) {
+			case *deck.Heading:
+				size := []int{3600, 3200, 2800, 2400, 2200, 2000}[b.Level-1]
+				paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"><a:spcBef><a:spcPts val="1200"/></a:spcBef><a:buNone/></a:pPr>%s</a:p>`, marL, p.runs(rels, flattenInlines(b.Inlines), size, true, p.pal.Heading)))
+				textHeight += pptxTextHeight(deck.PlainText(b.Inlines), size, width) + 12*emuPerPoint
+			case *deck.Paragraph:
+				if img := imageOnly(b); img != nil {
+					media := p.loadMedia(img.Src)
+					if media == nil {
+						// SVG, WebP and remote images fall back to their alt text
+						alt := deck.PlainText(img.Children)
+						paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"><a:buNone/></a:pPr>%s</a:p>`, marL, p.runs(rels, []textRun{{Text: "[" + alt + "]", Italic: true}}, 2000, false, p.pal.Foreground)))
+						textHeight += pptxTextHeight(alt, 2000, width)
+						continue
+					}
+					flushText()
+					relID := rels.add("image", "../media/"+media.part, false)
+					maxW := float64(width)
+					maxH := float64(pptxHeight - pptxMargin - top)
+					w, h := float64(media.width*emuPerPixel), float64(media.height*emuPerPixel)
+					fit := math.Min(1, math.Min(maxW/w, maxH/h))
+					if fit <= 0 {
+						continue
+					}
+					cx, cy := int64(w*fit), int64(h*fit)
+					shapes = append(shapes, fmt.Sprintf(`<p:pic><p:nvPicPr><p:cNvPr id="%d" name="Picture" descr="%s"/><p:cNvPicPr><a:picLocks noChangeAspect="1"/></p:cNvPicPr><p:nvPr/></p:nvPicPr><p:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></p:blipFill><p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr></p:pic>`,
+						nextID(), xmlText(deck.PlainText(img.Children)), relID, pptxXfrmRaw(boxX+marL, top, cx, cy)))
+					top += cy + pptxMargin/2
+					continue
+				}
+				paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"><a:spcAft><a:spcPts val="900"/></a:spcAft><a:buNone/></a:pPr>%s</a:p>`, marL, p.runs(rels, flattenInlines(b.Inlines), 2000, false, p.pal.Foreground)))
+				textHeight += pptxTextHeight(deck.PlainText(b.Inlines), 2000, width) + 9*emuPerPoint
+			case *deck.List:
+				bullet := `<a:buFont typeface="Arial"/><a:buChar char="•"/>`
+				if b.Ordered {
+					bullet = fmt.Sprintf(`<a:buFont typeface="+mj-lt"/><a:buAutoNum type="arabicPeriod" startAt="%d"/>`, b.Start)
+				}
+				itemL := marL + 457200
+				for _, item := range b.Items {
+					rest := item.Blocks
+					text := ""
+					var runs []textRun
+					if len(rest) > 0 {
+						if para, ok := rest[0].(*deck.Paragraph); ok && imageOnly(para) == nil {
+							text, runs = deck.PlainText(para.Inlines), flattenInlines(para.Inlines)
+							rest = rest[1:]
+						}
+					}
+					paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d" lvl="%d" indent="-342900"><a:spcAft><a:spcPts val="600"/></a:spcAft><a:buClr><a:srgbClr val="%s"/></a:buClr>%s</a:pPr>%s</a:p>`,
+						itemL, level, pptxColor(p.pal.Foreground), bullet, p.runs(rels, runs, 2000, false, p.pal.Foreground)))
+					textHeight += pptxTextHeight(text, 2000, width-457200) + 6*emuPerPoint
+					walk(rest, itemL, level+1)
+				}
+			case *deck.BlockQuote:
+				walk(b.Blocks, marL+457200, level)
+			case *deck.Columns:
+				flushText()
+				outerX, outerW := boxX, boxW
+				colTop, bottom := top, top
+				x := boxX + marL
+				gap := float64(pptxMargin / 2)
+				for i, w := range columnWidths(b.Columns, float64(width), gap) {
+					if w > 0 {
+						boxX, boxW, top = x, int64(w), colTop
+						walk(b.Columns[i].Blocks, 0, level)
+						flushText()
+						bottom = max(bottom, top)
+					}
+					x += int64(w + gap)
+				}
+				boxX, boxW, top = outerX, outerW, bottom
+			case *deck.Table:
+				flushText()
+				frame, height := p.table(rels, b, nextID(), boxX+marL, top, width)
+				shapes = append(shapes, frame)
+				top += height + pptxMargin/2
+			case *deck.ThematicBreak:
+				flushText()
+				shapes = append(shapes, fmt.Sprintf(`<p:cxnSp><p:nvCxnSpPr><p:cNvPr id="%d" name="Rule"/><p:cNvCxnSpPr/><p:nvPr/></p:nvCxnSpPr><p:spPr>%s<a:prstGeom prst="line"><a:avLst/></a:prstGeom><a:ln w="12700"><a:solidFill><a:srgbClr val="%s"/></a:solidFill></a:ln></p:spPr></p:cxnSp>`,
+					nextID(), pptxXfrmRaw(boxX+marL, top+6*emuPerPoint, width, 0), pptxColor(p.pal.Foreground)))
+				top += 12 * emuPerPoint
+			case *deck.CodeBlock:
+				flushText()
+				lines := highlight.Lines(highlight.Tokens(strings.TrimSuffix(b.Code, "\n"), b.Lang))
+				var codeParas []string
+				for _, line := range lines {
+					var runs strings.Builder
+					for _, t := range line {
+						fmt.Fprintf(&runs, `<a:r><a:rPr lang="en-US" sz="1400" dirty="0"><a:solidFill><a:srgbClr val="%s"/></a:solidFill><a:latin typeface="Courier New"/><a:cs typeface="Courier New"/></a:rPr><a:t>%s</a:t></a:r>`,
+							pptxColor(p.pal.tokenColor(t.Kind)), xmlText(t.Text))
+					}
+					codeParas = append(codeParas, "<a:p>"+runs.String()+"</a:p>")
+				}
+				height := int64(len(lines))*14*emuPerPoint*12/10 + 2*91440
+				shapes = append(shapes, fmt.Sprintf(`<p:sp><p:nvSpPr><p:cNvPr id="%d" name="Code"/><p:cNvSpPr txBox="1"/><p:nvPr/></p:nvSpPr><p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom><a:solidFill><a:srgbClr val="%s"/></a:solidFill></p:spPr><p:txBody><a:bodyPr wrap="none" lIns="182880" tIns="91440" rIns="182880" bIns="91440"><a:normAutofit/></a:bodyPr><a:lstStyle/>%s</p:txBody></p:sp>`,
+					nextID(), pptxXfrmRaw(boxX+marL, top, width, height), pptxColor(p.pal.CodeBg), strings.Join(codeParas, "")))
+				top += height + pptxMargin/2
+			}
+		}
+	}
+	walk(blocks, 0, 0)
+	flushText()
+
+	if len(slide.Node.Notes) > 0 {
+		rels.add("notesSlide", fmt.Sprintf("../notesSlides/notesSlide%d.xml", number), false)
+		if err := p.notesSlide(number, slide.Node.Notes); err != nil {
+			return err
+		}
+	}
+
+	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:sld %s %s %s><p:cSld>%s<p:spTree><p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/>%s</p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:sld>`,
+		nsA, nsR, nsP, p.background(p.pal.slideBackground(slide)), strings.Join(shapes, ""))
+	if err := p.write(fmt.Sprintf("ppt/slides/slide%d.xml", number), content); err != nil {
+		return err
+	}
+	return p.write(fmt.Sprintf("ppt/slides/_rels/slide%d.xml.rels", number), rels.xml())
+}
+
+// table builds a native table with equally wide columns and returns it with
+// its estimated height.
+func (p *pptxWriter) table(rels *pptxRels, t *deck.Table, id int, x, y, width int64) (string, int64) {
+	colWidth := width / int64(len(t.Align))
+	line := func(tag string) string {
+		return fmt.Sprintf(`<a:%s w="9525"><a:solidFill><a:srgbClr val="%s"/></a:solidFill></a:%s>`, tag, pptxColor(p.pal.Border), tag)
+	}
+	borders := line("lnL") + line("lnR") + line("lnT") + line("lnB")
+
+	var b strings.Builder
+	var height int64
+	for i, row := range append([][]*deck.TableCell{t.Header}, t.Rows...) {
+		rowHeight := int64(0)
+		var cells strings.Builder
+		for c, cell := range row {
+			algn := map[string]string{"left": "l", "center": "ctr", "right": "r"}[t.Align[c]]
+			if algn == "" {
+				algn = "l"
+			}
+			fill := ""
+			if i == 0 {
+				fill = fmt.Sprintf(`<a:solidFill><a:srgbClr val="%s"/></a:solidFill>`, pptxColor(p.pal.TableHead))
+			}
+			fmt.Fprintf(&cells, `<a:tc><a:txBody><a:bodyPr/><a:lstStyle/><a:p><a:pPr algn="%s"/>%s</a:p></a:txBody><a:tcPr>%s%s</a:tcPr></a:tc>`,
+				algn, p.runs(rels, flattenInlines(cell.Inlines), 1600, i == 0, p.pal.Foreground), borders, fill)
+			if h := pptxTextHeight(deck.PlainText(cell.Inlines), 1600, colWidth-182880); h > rowHeight {
+				rowHeight = h
+			}
+		}
+		rowHeight += 91440
+		height += rowHeight
+		fmt.Fprintf(&b, `<a:tr h="%d">%s</a:tr>`, rowHeight, cells.String())
+	}
+
+	grid := strings.Repeat(fmt.Sprintf(`<a:gridCol w="%d"/>`, colWidth), len(t.Align))
+	return fmt.Sprintf(`<p:graphicFrame><p:nvGraphicFramePr><p:cNvPr id="%d" name="Table"/><p:cNvGraphicFramePr><a:graphicFrameLocks noGrp="1"/></p:cNvGraphicFramePr><p:nvPr/></p:nvGraphicFramePr><p:xfrm><a:off x="%d" y="%d"/><a:ext cx="%d" cy="%d"/></p:xfrm><a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/table"><a:tbl><a:tblPr firstRow="1"/><a:tblGrid>%s</a:tblGrid>%s</a:tbl></a:graphicData></a:graphic></p:graphicFrame>`,
+		id, x, y, colWidth*int64(len(t.Align)), height, grid, b.String()), height
+}
+
+// runs converts styled text into DrawingML runs. Links get a hyperlink
+// relationship on the slide.
+func (p *pptxWriter) runs(rels *pptxRels, runs []textRun, size int, bold bool, color string) string {
+	var b strings.Builder
+	for _, run := range runs {
+		attrs := fmt.Sprintf(` lang="en-US" sz="%d" dirty="0"`, size)
+		if bold || run.Bold {
+			attrs += ` b="1"`
+		}
+		if run.Italic {
+			attrs += ` i="1"`
+		}
+		c := color
+		if run.Link {
+			c = p.pal.Link
+		}
+		inner := fmt.Sprintf(`<a:solidFill><a:srgbClr val="%s"/></a:solidFill>`, pptxColor(c))
+		if run.Code {
+			inner += `<a:latin typeface="Courier New"/><a:cs typeface="Courier New"/>`
+		}
+		if run.Link && run.URL != "" {
+			inner += fmt.Sprintf(`<a:hlinkClick r:id="%s"/>`, rels.add("hyperlink", run.URL, true))
+		}
+		fmt.Fprintf(&b, `<a:r><a:rPr%s>%s</a:rPr><a:t>%s</a:t></a:r>`, attrs, inner, xmlText(run.Text))
+	}
+	return b.String()
+}
+
+func (p *pptxWriter) background(color string) string {
+	return fmt.Sprintf(`<p:bg><p:bgPr><a:solidFill><a:srgbClr val="%s"/></a:solidFill><a:effectLst/></p:bgPr></p:bg>`, pptxColor(color))
+}
+
+// pptxTextHeight estimates how tall wrapped text will be, assuming an
+// average glyph is half an em wide.
+func pptxTextHeight(text string, size int, width int64) int64 {
+	pt := float64(size) / 100
+	perLine := float64(width) / (pt * 0.5 * emuPerPoint)
+	lines := math.Max(1, math.Ceil(float64(len([]rune(text)))/perLine))
+	return int64(lines * pt * 1.2 * emuPerPoint)
+}
+
+func pptxXfrm(x, y, cx, cy int64) string {
+	return `<p:spPr>` + pptxXfrmRaw(x, y, cx, cy) + `</p:spPr>`
+}
+
+func pptxXfrmRaw(x, y, cx, cy int64) string {
+	return fmt.Sprintf(`<a:xfrm><a:off x="%d" y="%d"/><a:ext cx="%d" cy="%d"/></a:xfrm>`, x, y, cx, cy)
+}
+
+// loadMedia copies a local PNG, JPEG or GIF into ppt/media once.
+func (p *pptxWriter) loadMedia(src string) *pptxMedia {
+	if m, seen := p.media[src]; seen {
+		return m
+	}
+	p.media[src] = nil
+
+	lower := strings.ToLower(src)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "data:") {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(p.assetDir, filepath.FromSlash(strings.TrimPrefix(src, "/"))))
+	if err != nil {
+		return nil
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	ext := map[string]string{"png": "png", "jpeg": "jpeg", "gif": "gif"}[format]
+	if ext == "" {
+		return nil
+	}
+
+	p.mediaSeq++
+	m := &pptxMedia{part: fmt.Sprintf("image%d.%s", p.mediaSeq, ext), width: cfg.Width, height: cfg.Height}
+	if err := p.write("ppt/media/"+m.part, string(data)); err != nil {
+		return nil
+	}
+	p.media[src] = m
+	return m
+}
+
+func (p *pptxWriter) notesSlide(number int, notes []deck.Block) error {
+	var paras []string
+	var walk func(blocks []deck.Block, marL int64)
+	walk = func(blocks []deck.Block, marL int64) {
+		for _, block := range blocks {
+			switch b := block.(type) {
+			case *deck.Heading:
+				paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"/>%s</a:p>`, marL, p.notesRuns(b.Inlines)))
+			case *deck.Paragraph:
+				paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"/>%s</a:p>`, marL, p.notesRuns(b.Inlines)))
+			case *deck.List:
+				for _, item := range b.Items {
+					rest := item.Blocks
+					var inlines []deck.Inline
+					if len(rest) > 0 {
+						if para, ok := rest[0].(*deck.Paragraph); ok {
+							inlines, rest = para.Inlines, rest[1:]
+						}
+					}
+					paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d" indent="-228600"><a:buChar char="•"/></a:pPr>%s</a:p>`, marL+228600, p.notesRuns(inlines)))
+					walk(rest, marL+228600)
+				}
+			case *deck.BlockQuote:
+				walk(b.Blocks, marL+228600)
+			case *deck.Table:
+				for _, row := range append([][]*deck.TableCell{b.Header}, b.Rows...) {
+					var cells []string
+					for _, cell := range row {
+						cells = append(cells, deck.PlainText(cell.Inlines))
+					}
+					paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"/><a:r><a:rPr lang="en-US" dirty="0"/><a:t>%s</a:t></a:r></a:p>`, marL, xmlText(strings.Join(cells, " | "))))
+				}
+			case *deck.CodeBlock:
+				for _, line := range strings.Split(strings.TrimSuffix(b.Code, "\n"), "\n") {
+					paras = append(paras, fmt.Sprintf(`<a:p><a:pPr marL="%d"/><a:r><a:rPr lang="en-US" dirty="0"><a:latin typeface="Courier New"/></a:rPr><a:t>%s</a:t></a:r></a:p>`, marL, xmlText(line)))
+				}
+			}
+		}
+	}
+	walk(notes, 0)
+
+	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:notes %s %s %s><p:cSld><p:spTree><p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/><p:sp><p:nvSpPr><p:cNvPr id="2" name="Slide Image"/><p:cNvSpPr><a:spLocks noGrp="1" noRot="1" noChangeAspect="1"/></p:cNvSpPr><p:nvPr><p:ph type="sldImg"/></p:nvPr></p:nvSpPr><p:spPr>%s</p:spPr></p:sp><p:sp><p:nvSpPr><p:cNvPr id="3" name="Notes"/><p:cNvSpPr><a:spLocks noGrp="1"/></p:cNvSpPr><p:nvPr><p:ph type="body" idx="1"/></p:nvPr></p:nvSpPr><p:spPr>%s</p:spPr><p:txBody><a:bodyPr/><a:lstStyle/>%s</p:txBody></p:sp></p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:notes>`,
+		nsA, nsR, nsP, pptxXfrmRaw(381000, 685800, 6096000, 3429000), pptxXfrmRaw(685800, 4343400, 5486400, 4114800), strings.Join(paras, ""))
+
+	rels := &pptxRels{}
+	rels.add("notesMaster", "../notesMasters/notesMaster1.xml", false)
+	rels.add("slide", fmt.Sprintf("../slides/slide%d.xml", number), false)
+	p.notes = append(p.notes, number)
+	if err := p.write(fmt.Sprintf("ppt/notesSlides/notesSlide%d.xml", number), content); err != nil {
+		return err
+	}
+	return p.write(fmt.Sprintf("ppt/notesSlides/_rels/notesSlide%d.xml.rels", number), rels.xml())
+}
+
+func (p *pptxWriter) notesRuns(inlines []deck.Inline) string {
+	var b strings.Builder
+	for _, run := range flattenInlines(inlines) {
+		attrs := ` lang="en-US" dirty="0"`
+		if run.Bold {
+			attrs += ` b="1"`
+		}
+		if run.Italic {
+			attrs += ` i="1"`
+		}
+		inner := ""
+		if run.Code {
+			inner = `<a:latin typeface="Courier New"/>`
+		}
+		fmt.Fprintf(&b, `<a:r><a:rPr%s>%s</a:rPr><a:t>%s</a:t></a:r>`, attrs, inner, xmlText(run.Text))
+	}
+	return b.String()
+}
+
+// packageParts writes everything besides the slides: content types, the
+// presentation, master, layout, theme, notes master and document properties.
+func (p *pptxWriter) packageParts() error {
+	slides := len(p.deck.Slides)
+
+	var types strings.Builder
+	types.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Default Extension="png" ContentType="image/png"/><Default Extension="jpeg" ContentType="image/jpeg"/><Default Extension="gif" ContentType="image/gif"/>`)
+	overrides := []struct{ part, typ string }{
+		{"/ppt/presentation.xml", "presentationml.presentation.main+xml"},
+		{"/ppt/slideMasters/slideMaster1.xml", "presentationml.slideMaster+xml"},
+		{"/ppt/slideLayouts/slideLayout1.xml", "presentationml.slideLayout+xml"},
+		{"/ppt/notesMasters/notesMaster1.xml", "presentationml.notesMaster+xml"},
+		{"/ppt/theme/theme1.xml", "theme+xml"},
+		{"/ppt/theme/theme2.xml", "theme+xml"},
+		{"/ppt/presProps.xml", "presentationml.presProps+xml"},
+		{"/ppt/viewProps.xml", "presentationml.viewProps+xml"},
+		{"/ppt/tableStyles.xml", "presentationml.tableStyles+xml"},
+		{"/docProps/app.xml", "extended-properties+xml"},
+	}
+	for _, o := range overrides {
+		fmt.Fprintf(&types, `<Override PartName="%s" ContentType="application/vnd.openxmlformats-officedocument.%s"/>`, o.part, o.typ)
+	}
+	types.WriteString(`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`)
+	for i := 1; i <= slides; i++ {
+		fmt.Fprintf(&types, `<Override PartName="/ppt/slides/slide%d.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slide+xml"/>`, i)
+	}
+	for _, n := range p.notes {
+		fmt.Fprintf(&types, `<Override PartName="/ppt/notesSlides/notesSlide%d.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.notesSlide+xml"/>`, n)
+	}
+	types.WriteString(`</Types>`)
+
+	rootRels := &pptxRels{}
+	rootRels.add("officeDocument", "ppt/presentation.xml", false)
+	rootRels.entries = append(rootRels.entries,
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>`,
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>`)
+
+	presRels := &pptxRels{}
+	master := presRels.add("slideMaster", "slideMasters/slideMaster1.xml", false)
+	var slideIDs strings.Builder
+	for i := 1; i <= slides; i++ {
+		id := presRels.add("slide", fmt.Sprintf("slides/slide%d.xml", i), false)
+		fmt.Fprintf(&slideIDs, `<p:sldId id="%d" r:id="%s"/>`, 255+i, id)
+	}
+	notesMaster := presRels.add("notesMaster", "notesMasters/notesMaster1.xml", false)
+	presRels.add("theme", "theme/theme1.xml", false)
+	presRels.add("presProps", "presProps.xml", false)
+	presRels.add("viewProps", "viewProps.xml", false)
+	presRels.add("tableStyles", "tableStyles.xml", false)
+
+	presentation := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:presentation %s %s %s saveSubsetFonts="1"><p:sldMasterIdLst><p:sldMasterId id="2147483648" r:id="%s"/></p:sldMasterIdLst><p:notesMasterIdLst><p:notesMasterId r:id="%s"/></p:notesMasterIdLst><p:sldIdLst>%s</p:sldIdLst><p:sldSz cx="%d" cy="%d"/><p:notesSz cx="6858000" cy="9144000"/><p:defaultTextStyle>%s</p:defaultTextStyle></p:presentation>`,
+		nsA, nsR, nsP, master, notesMaster, slideIDs.String(), pptxWidth, pptxHeight, pptxLevelStyles(p.pal.Foreground))
+
+	masterRels := &pptxRels{}
+	masterRels.add("slideLayout", "../slideLayouts/slideLayout1.xml", false)
+	masterRels.add("theme", "../theme/theme1.xml", false)
+	slideMaster := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:sldMaster %s %s %s><p:cSld>%s<p:spTree><p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/><p:sp><p:nvSpPr><p:cNvPr id="2" name="Title Placeholder"/><p:cNvSpPr><a:spLocks noGrp="1"/></p:cNvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr>%s<p:txBody><a:bodyPr anchor="b"/><a:lstStyle/><a:p><a:endParaRPr lang="en-US"/></a:p></p:txBody></p:sp></p:spTree></p:cSld><p:clrMap %s/><p:sldLayoutIdLst><p:sldLayoutId id="2147483649" r:id="rId1"/></p:sldLayoutIdLst><p:txStyles><p:titleStyle><a:lvl1pPr algn="l"><a:defRPr sz="3600" b="1"><a:solidFill><a:srgbClr val="%s"/></a:solidFill><a:latin typeface="+mj-lt"/></a:defRPr></a:lvl1pPr></p:titleStyle><p:bodyStyle>%s</p:bodyStyle><p:otherStyle>%s</p:otherStyle></p:txStyles></p:sldMaster>`,
+		nsA, nsR, nsP, p.background(p.pal.Background), pptxXfrm(pptxMargin, pptxMargin, pptxWidth-2*pptxMargin, 1143000), pptxClrMap, pptxColor(p.pal.Heading), pptxLevelStyles(p.pal.Foreground), pptxLevelStyles(p.pal.Foreground))
+
+	layoutRels := &pptxRels{}
+	layoutRels.add("slideMaster", "../slideMasters/slideMaster1.xml", false)
+	slideLayout := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:sldLayout %s %s %s type="titleOnly" preserve="1"><p:cSld name="Title Only"><p:spTree><p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/><p:sp><p:nvSpPr><p:cNvPr id="2" name="Title 1"/><p:cNvSpPr><a:spLocks noGrp="1"/></p:cNvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:spPr/><p:txBody><a:bodyPr/><a:lstStyle/><a:p><a:endParaRPr lang="en-US"/></a:p></p:txBody></p:sp></p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:sldLayout>`,
+		nsA, nsR, nsP)
+
+	notesMasterRels := &pptxRels{}
+	notesMasterRels.add("theme", "../theme/theme2.xml", false)
+	notesMasterXML := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<p:notesMaster %s %s %s><p:cSld><p:spTree><p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/><p:sp><p:nvSpPr><p:cNvPr id="2" name="Slide Image Placeholder"/><p:cNvSpPr><a:spLocks noGrp="1" noRot="1" noChangeAspect="1"/></p:cNvSpPr><p:nvPr><p:ph type="sldImg" idx="2"/></p:nvPr></p:nvSpPr><p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr></p:sp><p:sp><p:nvSpPr><p:cNvPr id="3" name="Notes Placeholder"/><p:cNvSpPr><a:spLocks noGrp="1"/></p:cNvSpPr><p:nvPr><p:ph type="body" sz="quarter" idx="3"/></p:nvPr></p:nvSpPr><p:spPr>%s<a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr><p:txBody><a:bodyPr/><a:lstStyle/><a:p><a:endParaRPr lang="en-US"/></a:p></p:txBody></p:sp></p:spTree></p:cSld><p:clrMap %s/><p:notesStyle>%s</p:notesStyle></p:notesMaster>`,
+		nsA, nsR, nsP, pptxXfrmRaw(381000, 685800, 6096000, 3429000), pptxXfrmRaw(685800, 4343400, 5486400, 4114800), pptxClrMap, pptxLevelStyles("#000000"))
+
+	creator := p.deck.Author
+	if creator == "" {
+		creator = "slides.md"
+	}
+	core := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><dc:title>%s</dc:title><dc:creator>%s</dc:creator></cp:coreProperties>`,
+		xmlText(p.deck.Title), xmlText(creator))
+	app := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties"><Application>slides.md</Application><Slides>%d</Slides><Notes>%d</Notes></Properties>`,
+		slides, len(p.notes))
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", rootRels.xml()},
+		{"docProps/core.xml", core},
+		{"docProps/app.xml", app},
+		{"ppt/presentation.xml", presentation},
+		{"ppt/_rels/presentation.xml.rels", presRels.xml()},
+		{"ppt/presProps.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + fmt.Sprintf(`<p:presentationPr %s %s %s/>`, nsA, nsR, nsP)},
+		{"ppt/viewProps.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + fmt.Sprintf(`<p:viewPr %s %s %s><p:normalViewPr><p:restoredLeft sz="15620"/><p:restoredTop sz="80000"/></p:normalViewPr><p:gridSpacing cx="76200" cy="76200"/></p:viewPr>`, nsA, nsR, nsP)},
+		{"ppt/tableStyles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" + fmt.Sprintf(`<a:tblStyleLst %s def="{5C22544A-7EE6-4342-B048-85BDC9FD1C3A}"/>`, nsA)},
+		{"ppt/slideMasters/slideMaster1.xml", slideMaster},
+		{"ppt/slideMasters/_rels/slideMaster1.xml.rels", masterRels.xml()},
+		{"ppt/slideLayouts/slideLayout1.xml", slideLayout},
+		{"ppt/slideLayouts/_rels/slideLayout1.xml.rels", layoutRels.xml()},
+		{"ppt/notesMasters/notesMaster1.xml", notesMasterXML},
+		{"ppt/notesMasters/_rels/notesMaster1.xml.rels", notesMasterRels.xml()},
+		{"ppt/theme/theme1.xml", p.theme()},
+		{"ppt/theme/theme2.xml", p.theme()},
+	}
+	for _, part := range parts {
+		if err := p.write(part.name, part.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const pptxClrMap = `bg1="lt1" tx1="dk1" bg2="lt2" tx2="dk2" accent1="accent1" accent2="accent2" accent3="accent3" accent4="accent4" accent5="accent5" accent6="accent6" hlink="hlink" folHlink="folHlink"`
+
+// pptxLevelStyles gives the nine outline levels a default size and color.
+func pptxLevelStyles(color string) string {
+	var b strings.Builder
+	for lvl := 1; lvl <= 9; lvl++ {
+		fmt.Fprintf(&b, `<a:lvl%dpPr marL="%d" algn="l"><a:defRPr sz="2000"><a:solidFill><a:srgbClr val="%s"/></a:solidFill><a:latin typeface="+mn-lt"/></a:defRPr></a:lvl%dpPr>`,
+			lvl, (lvl-1)*457200, pptxColor(color), lvl)
+	}
+	return b.String()
+}
+
+// theme writes a DrawingML theme whose dark/light colors follow the deck theme.
+func (p *pptxWriter) theme() string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<a:theme %s name="%s"><a:themeElements><a:clrScheme name="slides.md"><a:dk1><a:srgbClr val="%s"/></a:dk1><a:lt1><a:srgbClr val="%s"/></a:lt1><a:dk2><a:srgbClr val="%s"/></a:dk2><a:lt2><a:srgbClr val="%s"/></a:lt2><a:accent1><a:srgbClr val="%s"/></a:accent1><a:accent2><a:srgbClr val="ED7D31"/></a:accent2><a:accent3><a:srgbClr val="A5A5A5"/></a:accent3><a:accent4><a:srgbClr val="FFC000"/></a:accent4><a:accent5><a:srgbClr val="5B9BD5"/></a:accent5><a:accent6><a:srgbClr val="70AD47"/></a:accent6><a:hlink><a:srgbClr val="%s"/></a:hlink><a:folHlink><a:srgbClr val="%s"/></a:folHlink></a:clrScheme><a:fontScheme name="slides.md"><a:majorFont><a:latin typeface="Arial"/><a:ea typeface=""/><a:cs typeface=""/></a:majorFont><a:minorFont><a:latin typeface="Arial"/><a:ea typeface=""/><a:cs typeface=""/></a:minorFont></a:fontScheme><a:fmtScheme name="slides.md"><a:fillStyleLst><a:solidFill><a:schemeClr val="phClr"/></a:solidFill><a:solidFill><a:schemeClr val="phClr"/></a:solidFill><a:solidFill><a:schemeClr val="phClr"/></a:solidFill></a:fillStyleLst><a:lnStyleLst><a:ln w="6350"><a:solidFill><a:schemeClr val="phClr"/></a:solidFill></a:ln><a:ln w="12700"><a:solidFill><a:schemeClr val="phClr"/></a:solidFill></a:ln><a:ln w="19050"><a:solidFill><a:schemeClr val="phClr"/></a:solidFill></a:ln></a:lnStyleLst><a:effectStyleLst><a:effectStyle><a:effectLst/></a:effectStyle><a:effectStyle><a:effectLst/></a:effectStyle><a:effectStyle><a:effectLst/></a:effectStyle></a:effectStyleLst><a:bgFillStyleLst><a:solidFill><a:schemeClr val="phClr"/></a:solidFill><a:solidFill><a:schemeClr val="phClr"/></a:solidFill><a:solidFill><a:schemeClr val="phClr"/></a:solidFill></a:bgFillStyleLst></a:fmtScheme></a:themeElements></a:theme>`,
+		nsA, xmlText(p.deck.Theme.Name),
+		pptxColor(p.pal.Foreground), pptxColor(p.pal.Background), pptxColor(p.pal.Heading), pptxColor(p.pal.CodeBg),
+		pptxColor(p.pal.Link), pptxColor(p.pal.Link), pptxColor(p.pal.Link))
+}
@@ -0,0 +1,359 @@
+package render
+
+import (
+	"html/template"
+	"io"
+
+	"slides/deck"
+)
+
+// Presenter renders the speaker view: current slide, a preview of the
+// next one, the notes and a timer. It follows the audience window through a
+// BroadcastChannel, so both need to be open in the same browser. When opened
+// with the driver token it also moves every follower.
+func Presenter(w io.Writer, d *deck.Deck, opts Options) error {
+	tmpl := `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Presenter — {{.Title}}</title>
+    <link rel="stylesheet" href="{{.Base}}style.css">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
+            margin: 0;
+            padding: 0;
+            height: 100vh;
+            overflow: hidden;
+        }
+        .presenter {
+            display: grid;
+            grid-template-columns: 3fr 2fr;
+            grid-template-rows: auto 1fr 1fr;
+            gap: 16px;
+            height: 100vh;
+            padding: 16px;
+            box-sizing: border-box;
+        }
+        .bar {
+            grid-column: 1 / -1;
+            display: flex;
+            align-items: center;
+            gap: 24px;
+            font-size: 14px;
+        }
+        .bar .spacer { flex: 1; }
+        .timer {
+            font-size: 28px;
+            font-variant-numeric: tabular-nums;
+        }
+        .timer.over { color: #dc2626; }
+        .label {
+            font-size: 12px;
+            text-transform: uppercase;
+            letter-spacing: 0.08em;
+            opacity: 0.6;
+            margin-bottom: 6px;
+        }
+        .panel {
+            display: flex;
+            flex-direction: column;
+            min-height: 0;
+        }
+        .panel .slide {
+            flex: 1;
+            display: block;
+            padding: 32px;
+            box-sizing: border-box;
+            overflow: auto;
+            border: 1px solid currentColor;
+            border-radius: 6px;
+        }
+        .panel .slide.layout-center {
+            display: flex;
+            flex-direction: column;
+            justify-content: center;
+            text-align: center;
+        }
+        .current { grid-row: 2 / span 2; }
+        .current .slide { font-size: 0.9em; }
+        .next .slide { font-size: 0.55em; opacity: 0.85; }
+        .notes-body {
+            flex: 1;
+            overflow: auto;
+            font-size: 20px;
+            line-height: 1.5;
+        }
+        .sources { display: none; }
+        button {
+            padding: 6px 14px;
+            cursor: pointer;
+            border: 1px solid currentColor;
+            border-radius: 4px;
+            font-size: 13px;
+        }
+        pre {
+            padding: 16px;
+            border-radius: 6px;
+            overflow-x: auto;
+        }
+        .fragment { opacity: 0.3; }
+        .fragment.visible { opacity: 1; }
+        pre[data-line-steps] .line {
+            display: block;
+            margin: 0 -16px;
+            padding: 0 16px;
+        }
+        pre[data-line-steps]:has(.highlighted) .line {
+            opacity: 0.45;
+        }
+        pre[data-line-steps] .line.highlighted {
+            opacity: 1;
+            background: rgba(127, 127, 127, 0.18);
+        }
+        .columns {
+            display: grid;
+            grid-template-columns: var(--columns);
+            gap: 24px;
+            align-items: start;
+        }
+        .column { min-width: 0; }
+        .column > :first-child { margin-top: 0; }
+        @media (max-width: 700px) {
+            .columns { grid-template-columns: 1fr; }
+        }
+        table {
+            border-collapse: collapse;
+        }
+        th, td {
+            padding: 6px 10px;
+            border-width: 1px;
+            border-style: solid;
+        }
+        img {
+            max-width: 100%;
+            height: auto;
+        }
+    </style>
+</head>
+<body>
+    <div class="presenter">
+        <div class="bar">
+            <strong>{{.Title}}</strong>
+            <span>Slide <span id="current">1</span> / {{.Sections}}<span id="step"></span></span>
+            <span class="spacer"></span>
+            <span class="label">Elapsed</span>
+            <span class="timer" id="elapsed">00:00</span>
+            {{if .TalkSeconds}}
+            <span class="label">Remaining</span>
+            <span class="timer" id="remaining">00:00</span>
+            {{end}}
+            <button onclick="resetTimer()">Reset timer</button>
+            <button onclick="window.open('{{.AudienceURL}}', 'slides-audience:' + deckKey)">Open audience window</button>
+        </div>
+        <div class="panel current">
+            <div class="label">Current</div>
+            <div class="slide" id="current-slide"></div>
+        </div>
+        <div class="panel next">
+            <div class="label">Next</div>
+            <div class="slide" id="next-slide"></div>
+        </div>
+        <div class="panel">
+            <div class="label">Notes</div>
+            <div class="notes-body" id="notes"></div>
+        </div>
+    </div>
+    <div class="sources">
+        {{range .Slides}}
+        <template class="slide-source" data-label="{{.Label}}" data-section="{{.Section}}" data-sub="{{.Sub}}" data-class="{{.Class}}" data-background="{{.Background}}" data-background-image="{{.BackgroundImage}}">{{.Content}}</template>
+        <template class="notes-source">{{.Notes}}</template>
+        {{end}}
+    </div>
+    <script>
+        let currentSlide = 0;
+        let currentStep = 0;
+        const slideSources = document.querySelectorAll('.slide-source');
+        const notesSources = document.querySelectorAll('.notes-source');
+        const totalSlides = {{len .Slides}};
+        const talkSeconds = {{.TalkSeconds}};
+
+        // deckKey tells decks served side by side apart, so they don't steer
+        // each other or share windows and saved state. Static builds have no
+        // base; their directory stands in for it.
+        const deckKey = {{.Base}} || location.pathname.replace(/[^/]*$/, '');
+        const channel = 'BroadcastChannel' in window ? new BroadcastChannel('slides-md:' + deckKey) : null;
+
+        // Opened with ?token=..., the presenter drives every follower
+        const params = new URLSearchParams(location.search);
+        if (params.has('token')) {
+            sessionStorage.setItem('slides-driver-token', params.get('token'));
+            history.replaceState(null, '', location.pathname);
+        }
+        const driverToken = {{if .Static}}null{{else}}sessionStorage.getItem('slides-driver-token'){{end}};
+
+        {{template "steps"}}
+
+        {{template "navigation"}}
+
+        const positions = slidePositions(slideSources);
+
+        function stepCount(n) {
+            return slideSteps(slideSources[n].content).length;
+        }
+
+        // show puts a slide source into a panel with its first n steps revealed
+        function show(id, n, step) {
+            const panel = document.getElementById(id);
+            const source = slideSources[n];
+            panel.innerHTML = source.innerHTML;
+            panel.className = 'slide ' + source.dataset.class;
+            panel.style.background = source.dataset.background;
+            if (source.dataset.backgroundImage) {
+                panel.style.background = 'center / cover no-repeat url("' + source.dataset.backgroundImage + '")';
+            }
+            applyStep(panel, step);
+        }
+
+        // The "next" panel previews the next step, which may still be on the
+        // current slide
+        function render() {
+            const total = stepCount(currentSlide);
+            show('current-slide', currentSlide, currentStep);
+            if (currentStep < total) {
+                show('next-slide', currentSlide, currentStep + 1);
+            } else if (currentSlide + 1 < totalSlides) {
+                show('next-slide', currentSlide + 1, 0);
+            } else {
+                const panel = document.getElementById('next-slide');
+                panel.className = 'slide';
+                panel.style.background = '';
+                panel.innerHTML = '<p>End of deck</p>';
+            }
+            document.getElementById('notes').innerHTML = notesSources[currentSlide].innerHTML;
+            document.getElementById('current').textContent = slideSources[currentSlide].dataset.label;
+            document.getElementById('step').textContent = total ? ' · ' + currentStep + '/' + total : '';
+        }
+
+        // Same wrap-around behaviour as the audience view
+        function showSlide(n, step, remote) {
+            currentSlide = n;
+            if (currentSlide >= totalSlides) currentSlide = 0;
+            if (currentSlide < 0) currentSlide = totalSlides - 1;
+            currentStep = Math.min(Math.max(step, 0), stepCount(currentSlide));
+            render();
+            if (remote) {
+                return;
+            }
+            if (channel) {
+                channel.postMessage({ slide: currentSlide, step: currentStep });
+            }
+            if (driverToken) {
+                fetch('{{.Base}}api/state', {
+                    method: 'POST',
+                    headers: { 'Authorization': 'Bearer ' + driverToken, 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ slide: currentSlide, step: currentStep })
+                });
+            }
+        }
+
+        if (channel) {
+            channel.onmessage = function(e) {
+                const msg = e.data || {};
+                const step = msg.step || 0;
+                if (typeof msg.slide === 'number' && (msg.slide !== currentSlide || step !== currentStep)) {
+                    showSlide(msg.slide, step, true);
+                }
+            };
+        }
+
+        // The keys move through steps, sections and sub-slides as in the
+        // audience view
+        document.addEventListener('keydown', function(e) {
+            const to = navigationKeys[e.key] && move(navigationKeys[e.key], currentSlide, currentStep, positions, stepCount);
+            if (to) {
+                e.preventDefault();
+                showSlide(to.slide, to.step);
+            }
+        });
+
+        // Timer: elapsed since the view was opened (or reset), plus a countdown
+        // when a talk length was given with -duration
+        let startedAt = parseInt(sessionStorage.getItem('slides-presenter-start:' + deckKey) || '', 10) || Date.now();
+        sessionStorage.setItem('slides-presenter-start:' + deckKey, startedAt);
+
+        function resetTimer() {
+            startedAt = Date.now();
+            sessionStorage.setItem('slides-presenter-start:' + deckKey, startedAt);
+            tick();
+        }
+
+        function format(seconds) {
+            const sign = seconds < 0 ? '-' : '';
+            seconds = Math.abs(seconds);
+            const m = Math.floor(seconds / 60);
+            const s = seconds % 60;
+            return sign + String(m).padStart(2, '0') + ':' + String(s).padStart(2, '0');
+        }
+
+        function tick() {
+            const elapsed = Math.floor((Date.now() - startedAt) / 1000);
+            document.getElementById('elapsed').textContent = format(elapsed);
+            const remaining = document.getElementById('remaining');
+            if (remaining) {
+                remaining.textContent = format(talkSeconds - elapsed);
+                remaining.classList.toggle('over', elapsed > talkSeconds);
+            }
+        }
+        setInterval(tick, 1000);
+        tick();
+
+        // Initialize, then ask the audience window where it is
+        const restored = parseInt(sessionStorage.getItem('slides-presenter-current:' + deckKey) || '', 10);
+        const restoredStep = parseInt(sessionStorage.getItem('slides-presenter-step:' + deckKey) || '', 10);
+        sessionStorage.removeItem('slides-presenter-current:' + deckKey);
+        sessionStorage.removeItem('slides-presenter-step:' + deckKey);
+        showSlide(restored >= 0 && restored < totalSlides ? restored : 0, restoredStep || 0, true);
+        if (channel) {
+            channel.postMessage({ request: true });
+        }
+        {{if .LiveReload}}
+
+        // Live reload: the server pushes "reload" whenever the deck changes
+        (function() {
+            const source = new EventSource('{{.Base}}events');
+            source.addEventListener('reload', function() {
+                sessionStorage.setItem('slides-presenter-current:' + deckKey, currentSlide);
+                sessionStorage.setItem('slides-presenter-step:' + deckKey, currentStep);
+                location.reload();
+            });
+        })();
+        {{end}}
+    </script>
+</body>
+</html>`
+
+	t := template.Must(template.New("presenter").Parse(tmpl + stepScript + navigationScript))
+	data := struct {
+		Title       string
+		Slides      []deck.Slide
+		Sections    int
+		TalkSeconds int
+		Base        string
+		Static      bool
+		LiveReload  bool
+		AudienceURL string
+	}{
+		Title:       d.Title,
+		Slides:      d.Slides,
+		Sections:    d.Sections(),
+		TalkSeconds: int(opts.TalkLength.Seconds()),
+		Base:        opts.Base,
+		Static:      opts.Static,
+		LiveReload:  opts.LiveReload,
+		AudienceURL: opts.audienceURL(),
+	}
+
+	return t.Execute(w, data)
+}
@@ -0,0 +1,1143 @@
+// Package render turns a parsed deck into pages and files: the audience and
+// presenter HTML pages with their CSS, a PDF or a PowerPoint file.
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"slides/deck"
+	"slides/highlight"
+	"slides/theme"
+)
+
+// CSS is the theme's CSS followed by rules for its syntax palette.
+// Unknown token kinds are ignored.
+func CSS(t theme.Theme) string {
+	var b strings.Builder
+	b.WriteString(t.CSS)
+	for _, kind := range highlight.Kinds {
+		if color := strings.TrimSpace(t.Syntax[kind]); color != "" {
+			fmt.Fprintf(&b, "\n.tok-%s { color: %s; }", kind, color)
+		}
+	}
+	return b.String()
+}
+
+// Options controls the parts of a page that depend on how it is delivered.
+type Options struct {
+	Base       string        // prefix for server URLs: "/" when served, "" for a static build
+	Static     bool          // no server behind the page, so follow mode and events are left out
+	SingleFile bool          // everything inlined into one page, so there is no presenter.html
+	LiveReload bool          // reload when the server announces a change (-watch)
+	TalkLength time.Duration // countdown shown in the presenter view
+	Start      int           // Number of the slide shown before any script runs, 0 for the first
+	SearchURL  string        // endpoint the search box queries; empty searches the page itself
+}
+
+func (o Options) audienceURL() string {
+	if o.Static {
+		return o.Base + "index.html"
+	}
+	return o.Base
+}
+
+func (o Options) presenterURL() string {
+	if o.SingleFile {
+		return ""
+	}
+	if o.Static {
+		return o.Base + "presenter.html"
+	}
+	return o.Base + "presenter"
+}
+
+// classificationColors returns the banner background and foreground,
+// providing sensible defaults if theme values are empty.
+func classificationColors(theme theme.Theme) (string, string) {
+	bg, fg := theme.ClassificationBg, theme.ClassificationFg
+	if strings.TrimSpace(bg) == "" {
+		bg = "#5e81ac"
+	}
+	if strings.TrimSpace(fg) == "" {
+		fg = "#ffffff"
+	}
+	return bg, fg
+}
+
+// watermarkText is the text tiled across the page when the theme enables a
+// watermark: the configured text or the deck title, optionally dated.
+func watermarkText(theme theme.Theme, deckTitle string) string {
+	text := strings.TrimSpace(theme.WatermarkText)
+	if text == "" {
+		text = deckTitle
+	}
+	if theme.WatermarkAppendDate {
+		text = fmt.Sprintf("%s — %s", text, time.Now().Format("2006-01-02"))
+	}
+	return text
+}
+
+// HTML renders the audience page: every slide of d in its theme, with the
+// navigation, overview and search scripts.
+func HTML(w io.Writer, d *deck.Deck, opts Options) error {
+	slides, theme, pageTitle, transition := d.Slides, d.Theme, d.Title, d.Transition
+	tmpl := `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    {{if .InlineCSS}}
+    <style>{{.InlineCSS}}</style>
+    {{else}}
+    <link rel="stylesheet" href="{{.Base}}style.css">
+    {{end}}
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
+            margin: 0;
+            padding: 0;
+            display: flex;
+            justify-content: center;
+            align-items: center;
+            min-height: 100vh;
+            overflow: hidden;
+        }
+        .slide-container {
+            width: 90vw;
+            max-width: 1200px;
+            height: 90vh;
+            position: relative;
+        }
+        .watermark {
+            position: fixed; /* cover entire page */
+            inset: 0;
+            pointer-events: none;
+            z-index: 5;
+            /* Subtle diagonal grid lines */
+            background-image: repeating-linear-gradient(
+                45deg,
+                rgba(0,0,0, OP) 0,
+                rgba(0,0,0, OP) 1px,
+                transparent 1px,
+                transparent 60px
+            );
+        }
+        .watermark-texts {
+            position: fixed; /* cover entire page */
+            width: 160vw; /* oversize to cover corners after rotation */
+            height: 160vh;
+            left: 50%;
+            top: 50%;
+            transform: translate(-50%, -50%) rotate(-25deg);
+            display: grid;
+            grid-template-columns: repeat(8, 1fr);
+            grid-auto-rows: 120px;
+            gap: 28px;
+            opacity: OP;
+            color: currentColor;
+            z-index: 6;
+            pointer-events: none;
+        }
+        .watermark-texts span {
+            font-size: 36px; /* bigger, denser tiling */
+            font-weight: 700;
+            letter-spacing: 0.14em;
+            text-transform: uppercase;
+            white-space: nowrap;
+            justify-self: center;
+            align-self: center;
+        }
+        .slide {
+            display: none;
+            padding: 60px;
+            box-sizing: border-box;
+            overflow-y: auto;
+        }
+        .slide.active { display: block; }
+
+        /* Per-slide directives */
+        .slide.with-background {
+            position: relative;
+            min-height: 100%;
+            isolation: isolate;
+        }
+        .slide-background {
+            position: absolute;
+            inset: 0;
+            width: 100%;
+            height: 100%;
+            max-width: none;
+            margin: 0;
+            object-fit: cover;
+            z-index: -1;
+        }
+        .slide.layout-center {
+            min-height: 100%;
+            flex-direction: column;
+            justify-content: center;
+            text-align: center;
+        }
+        .slide.layout-center.active,
+        .transition-fade .slide.layout-center,
+        .transition-slide .slide.layout-center {
+            display: flex;
+        }
+
+        /* Transitions */
+        /* FADE: overlay slides and cross-fade */
+        .transition-fade { position: relative; }
+        .transition-fade .slide {
+            display: block; /* override base */
+            position: absolute;
+            top: 0; left: 0; right: 0; bottom: 0;
+            opacity: 0;
+            transition: opacity 220ms ease;
+            pointer-events: none;
+            z-index: 1;
+        }
+        .transition-fade .slide.active {
+            opacity: 1;
+            pointer-events: auto;
+        }
+
+        /* SLIDE: support direction-aware animations */
+        .transition-slide { position: relative; overflow: hidden; }
+        .transition-slide .slide {
+            display: block; /* override base */
+            position: absolute;
+            top: 0; left: 0; right: 0; bottom: 0;
+            transform: translateX(100%);
+            transition: transform 260ms ease;
+            pointer-events: none;
+            z-index: 1;
+        }
+        .transition-slide .slide.pre-right { transform: translateX(100%); }
+        .transition-slide .slide.pre-left  { transform: translateX(-100%); }
+        .transition-slide .slide.active    { transform: translateX(0); pointer-events: auto; }
+        .transition-slide .slide.exiting-left { transform: translateX(-100%); }
+        .transition-slide .slide.exiting-right { transform: translateX(100%); }
+
+        .transition-cut .slide { }
+        .controls {
+            position: fixed;
+            bottom: 20px;
+            left: 50%;
+            transform: translateX(-50%);
+            display: flex;
+            gap: 10px;
+            z-index: 1000;
+        }
+        button {
+            padding: 10px 20px;
+            cursor: pointer;
+            border: 1px solid currentColor;
+            border-radius: 4px;
+            font-size: 14px;
+            transition: opacity 0.2s;
+        }
+        button:hover {
+            opacity: 0.7;
+        }
+        code {
+            padding: 2px 6px;
+            border-radius: 3px;
+        }
+        pre {
+            padding: 16px;
+            border-radius: 6px;
+            overflow-x: auto;
+        }
+        pre code {
+            padding: 0;
+        }
+        .fragment {
+            visibility: hidden;
+            opacity: 0;
+            transition: opacity 0.3s, visibility 0.3s;
+        }
+        .fragment.visible {
+            visibility: visible;
+            opacity: 1;
+        }
+        pre[data-line-steps] .line {
+            display: block;
+            margin: 0 -16px;
+            padding: 0 16px;
+        }
+        pre[data-line-steps]:has(.highlighted) .line {
+            opacity: 0.45;
+            transition: opacity 0.2s;
+        }
+        pre[data-line-steps] .line.highlighted {
+            opacity: 1;
+            background: rgba(127, 127, 127, 0.18);
+        }
+        .columns {
+            display: grid;
+            grid-template-columns: var(--columns);
+            gap: 32px;
+            align-items: start;
+        }
+        .column { min-width: 0; }
+        .column > :first-child { margin-top: 0; }
+        @media (max-width: 700px) {
+            .columns { grid-template-columns: 1fr; }
+        }
+        table {
+            border-collapse: collapse;
+            margin: 16px 0;
+        }
+        th, td {
+            padding: 8px 14px;
+            border-width: 1px;
+            border-style: solid;
+        }
+        img {
+            max-width: 100%;
+            height: auto;
+            display: block;
+            margin: 16px 0;
+        }
+        h1 { font-size: 2.5em; }
+        h2 { font-size: 2em; }
+        h3 { font-size: 1.5em; }
+        h4 { font-size: 1.25em; }
+        .slide-counter {
+            position: fixed;
+            top: 20px;
+            right: 20px;
+            font-size: 14px;
+            opacity: 0.6;
+        }
+        .deck-title {
+            position: fixed;
+            top: 20px;
+            left: 20px;
+            font-size: 14px;
+            opacity: 0.8;
+        }
+        .theme-logo {
+            position: absolute;
+            top: 16px;
+            right: 16px;
+            max-width: 140px;
+            max-height: 60px;
+            object-fit: contain;
+            opacity: 0.9;
+            pointer-events: none;
+            z-index: 2;
+        }
+        .overview {
+            position: fixed;
+            inset: 0;
+            z-index: 1500;
+            overflow: auto;
+            padding: 40px;
+            box-sizing: border-box;
+            display: grid;
+            grid-auto-columns: 240px;
+            grid-auto-rows: 135px;
+            gap: 16px;
+            justify-content: center;
+            align-content: start;
+            background: inherit;
+        }
+        .overview[hidden] { display: none; }
+        .overview.flow { grid-template-columns: repeat(auto-fill, 240px); }
+        .overview-tile {
+            position: relative;
+            overflow: hidden;
+            cursor: pointer;
+            border: 2px solid transparent;
+            border-radius: 4px;
+        }
+        .overview-tile.current { border-color: currentColor; }
+        .overview-tile.selected { outline: 2px dashed currentColor; outline-offset: 3px; }
+        .search {
+            position: fixed;
+            top: 60px;
+            left: 50%;
+            transform: translateX(-50%);
+            z-index: 1600;
+            width: min(640px, 90vw);
+            max-height: 70vh;
+            display: flex;
+            flex-direction: column;
+            padding: 12px;
+            box-sizing: border-box;
+            background: inherit;
+            border: 1px solid currentColor;
+            border-radius: 8px;
+            box-shadow: 0 8px 32px rgba(0, 0, 0, 0.3);
+        }
+        .search[hidden] { display: none; }
+        .search input {
+            font: inherit;
+            font-size: 18px;
+            padding: 8px 10px;
+            color: inherit;
+            background: transparent;
+            border: 1px solid currentColor;
+            border-radius: 4px;
+        }
+        .search ol {
+            list-style: none;
+            margin: 8px 0 0;
+            padding: 0;
+            overflow-y: auto;
+        }
+        .search li {
+            padding: 8px 10px;
+            border-radius: 4px;
+            cursor: pointer;
+        }
+        .search li.selected { outline: 2px solid currentColor; }
+        .search .result-title { font-weight: 600; }
+        .search .result-snippet { font-size: 14px; opacity: 0.8; }
+        .search mark { background: rgba(255, 200, 0, 0.5); color: inherit; }
+        .overview-label {
+            position: absolute;
+            right: 6px;
+            bottom: 4px;
+            z-index: 1;
+            font-size: 12px;
+            opacity: 0.8;
+        }
+        .overview-tile .slide {
+            position: absolute;
+            top: 0;
+            left: 0;
+            width: 1200px;
+            height: 675px;
+            transform: scale(0.2);
+            transform-origin: 0 0;
+            pointer-events: none;
+            overflow: hidden;
+        }
+        .classification {
+            position: fixed;
+            top: 20px;
+            left: 50%;
+            transform: translateX(-50%);
+            display: inline-flex;
+            align-items: center;
+            justify-content: center;
+            font-weight: 600;
+            font-size: 13px;
+            letter-spacing: 0.08em;
+            padding: 4px 10px;
+            border-radius: 999px;
+            z-index: 1200;
+            pointer-events: none;
+        }
+    </style>
+</head>
+<body>
+    {{if .Classification.Label}}
+    <div class="classification" style="background: {{.Classification.Bg}}; color: {{.Classification.Fg}}">{{.Classification.Label}}</div>
+    {{end}}
+    <div class="deck-title">{{.DeckTitle}}</div>
+    <div class="slide-counter">
+        <span id="current">{{.Start.Label}}</span> / {{.Sections}}<span id="step"></span><span id="jump"></span>
+    </div>
+    <div class="slide-container transition-{{.Transition}}">
+        {{if .Watermark.Enabled}}
+        <div class="watermark"></div>
+        <div class="watermark-texts" id="wm-texts">
+            {{/* Render a tiled grid of texts */}}
+            {{range .Watermark.Repeat}}<span class="wm-item">{{$.Watermark.Text}}</span>{{end}}
+        </div>
+        {{end}}
+        {{if .Logo}}
+        <img class="theme-logo" src="{{.Logo}}" alt="Logo"/>
+        {{end}}
+        {{range .Slides}}
+        <div class="slide {{if eq .Number $.Start.Number}}active{{else}}pre-right{{end}}{{with .Class}} {{.}}{{end}}" id="slide-{{.Number}}" data-section="{{.Section}}" data-sub="{{.Sub}}"{{with .Layout}} data-layout="{{.}}"{{end}}{{with .Background}} style="background: {{.}}"{{end}}>
+            {{with .BackgroundImage}}<img class="slide-background" src="{{.}}" alt="">{{end}}
+            {{.Content}}
+        </div>
+        {{end}}
+    </div>
+    <div class="overview" id="overview" hidden></div>
+    <div class="search" id="search" hidden>
+        <input id="search-input" type="search" placeholder="Search slides" autocomplete="off" aria-label="Search slides">
+        <ol id="search-results"></ol>
+    </div>
+    <div class="controls">
+        <button onclick="previousSlide()">← Previous</button>
+        <button onclick="nextSlide()">Next →</button>
+        {{if not .Static}}
+        <button id="follow-toggle" onclick="setFollowing(!following)" title="Follow the presenter (F)">Following</button>
+        {{end}}
+    </div>
+    <script>
+        let currentSlide = 0;
+        let currentStep = 0;
+        const slides = document.querySelectorAll('.slide');
+        const totalSlides = {{len .Slides}};
+
+        // Keep the presenter view (and other windows of this browser) on the same slide
+        // deckKey tells decks served side by side apart, so they don't steer
+        // each other or share windows and saved state. Static builds have no
+        // base; their directory stands in for it.
+        const deckKey = {{.Base}} || location.pathname.replace(/[^/]*$/, '');
+        const channel = 'BroadcastChannel' in window ? new BroadcastChannel('slides-md:' + deckKey) : null;
+        let applyingRemote = false;
+
+        // Follow mode: a client holding the driver token pushes its position to
+        // the server, everyone else follows it unless they opt out to browse.
+        const params = new URLSearchParams(location.search);
+        if (params.has('token')) {
+            sessionStorage.setItem('slides-driver-token', params.get('token'));
+            params.delete('token');
+            history.replaceState(null, '', location.pathname + (params.toString() ? '?' + params : '') + location.hash);
+        }
+        const driverToken = {{if .Static}}null{{else}}sessionStorage.getItem('slides-driver-token'){{end}};
+        let following = !driverToken;
+        let driverState = null;
+
+        function setFollowing(on) {
+            following = on && !driverToken;
+            const toggle = document.getElementById('follow-toggle');
+            if (toggle) {
+                toggle.style.display = driverToken ? 'none' : '';
+                toggle.textContent = following ? 'Following' : 'Browsing';
+            }
+            if (following && driverState) {
+                applyingRemote = true;
+                goTo(driverState.slide, driverState.step || 0);
+                applyingRemote = false;
+            }
+        }
+
+        function announce() {
+            if (applyingRemote) {
+                return;
+            }
+            if (channel) {
+                channel.postMessage({ slide: currentSlide, step: currentStep });
+            }
+            if (driverToken) {
+                fetch('{{.Base}}api/state', {
+                    method: 'POST',
+                    headers: { 'Authorization': 'Bearer ' + driverToken, 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ slide: currentSlide, step: currentStep })
+                });
+            }
+        }
+
+        if (channel) {
+            channel.onmessage = function(e) {
+                const msg = e.data || {};
+                if (msg.request) {
+                    announce();
+                } else if (typeof msg.slide === 'number') {
+                    applyingRemote = true;
+                    goTo(msg.slide, msg.step || 0);
+                    applyingRemote = false;
+                }
+            };
+        }
+
+        {{template "steps"}}
+
+        {{template "navigation"}}
+
+        const positions = slidePositions(slides);
+
+        function label(n) {
+            const p = positions[n];
+            return p.sub ? p.section + '.' + p.sub : String(p.section);
+        }
+
+        function updateCounter() {
+            const total = slideSteps(slides[currentSlide]).length;
+            document.getElementById('current').textContent = label(currentSlide);
+            document.getElementById('step').textContent = total ? ' · ' + currentStep + '/' + total : '';
+        }
+
+        function showStep(n) {
+            currentStep = Math.min(Math.max(n, 0), slideSteps(slides[currentSlide]).length);
+            applyStep(slides[currentSlide], currentStep);
+            updateCounter();
+            updateHash(false);
+            announce();
+        }
+
+        // goTo moves to a position announced by another window or the driver
+        function goTo(slide, step) {
+            if (slide !== currentSlide) {
+                showSlide(slide, slide > currentSlide ? 1 : -1, step);
+            } else if (step !== currentStep) {
+                showStep(step);
+            }
+        }
+
+        // Deep links: the URL hash holds the slide's label and the number of
+        // revealed steps, as in #3.2/1. Changing slides adds a history entry,
+        // so back and forward walk through the slides seen; steps and moves
+        // made by the presenter only replace it.
+        let navigating = false;
+
+        function slideIndex(text) {
+            return Array.from(slides).findIndex((slide, n) => label(n) === text);
+        }
+
+        function parseHash(hash) {
+            const m = /^#(\d+(?:\.\d+)?)(?:\/(\d+))?$/.exec(hash);
+            const n = m ? slideIndex(m[1]) : -1;
+            return n < 0 ? null : { slide: n, step: parseInt(m[2] || '0', 10) };
+        }
+
+        function updateHash(newSlide) {
+            const hash = '#' + label(currentSlide) + (currentStep ? '/' + currentStep : '');
+            if (hash === location.hash) {
+                return;
+            }
+            if (newSlide && !applyingRemote && !navigating) {
+                history.pushState(null, '', hash);
+            } else {
+                history.replaceState(null, '', hash);
+            }
+        }
+
+        window.addEventListener('popstate', function() {
+            const target = parseHash(location.hash);
+            if (target) {
+                navigating = true;
+                setFollowing(false);
+                goTo(target.slide, target.step);
+                navigating = false;
+            }
+        });
+
+        // showSlide changes slides. The new slide starts with no steps
+        // revealed, or all of them when going backwards, unless step is given.
+        function showSlide(n, dir, step) {
+            const container = document.querySelector('.slide-container');
+            const transition = container.className.includes('transition-') ?
+                container.className.match(/transition-([a-z]+)/)[1] : 'cut';
+
+            const previousIndex = currentSlide;
+            const previous = slides[previousIndex];
+            previous.classList.remove('exiting');
+
+            currentSlide = n;
+            if (currentSlide >= totalSlides) currentSlide = 0;
+            if (currentSlide < 0) currentSlide = totalSlides - 1;
+
+            const next = slides[currentSlide];
+            const total = slideSteps(next).length;
+            if (step === undefined) {
+                step = (dir || 0) < 0 ? total : 0;
+            }
+            currentStep = Math.min(Math.max(step, 0), total);
+            applyStep(next, currentStep);
+
+            if (previous === next) {
+                // Ensure visible on first render
+                next.classList.add('active');
+                updateCounter();
+                updateHash(false);
+                return;
+            }
+
+            if (transition === 'fade') {
+                // Activate next first, then hide previous after tick
+                next.classList.add('active');
+                setTimeout(() => {
+                    previous.classList.remove('active');
+                }, 0);
+            } else if (transition === 'slide') {
+                // Direction-aware slide: use provided dir (1 forward, -1 backward)
+                const forward = (dir || 0) >= 0;
+                // Prepare next slide off-screen in the correct direction
+                next.classList.remove('pre-left','pre-right','exiting-left','exiting-right');
+                previous.classList.remove('pre-left','pre-right','exiting-left','exiting-right');
+                if (forward) {
+                    next.classList.add('pre-right');
+                    // force reflow
+                    void next.offsetWidth;
+                    previous.classList.add('exiting-left');
+                } else {
+                    next.classList.add('pre-left');
+                    void next.offsetWidth;
+                    previous.classList.add('exiting-right');
+                }
+                next.classList.add('active');
+                // After transition ends, clean up previous
+                previous.addEventListener('transitionend', function handler() {
+                    previous.classList.remove('active','exiting-left','exiting-right');
+                    previous.removeEventListener('transitionend', handler);
+                });
+                // Clean pre-* class on next after it finishes activating
+                next.addEventListener('transitionend', function cleanNext(e) {
+                    if (e.propertyName === 'transform') {
+                        next.classList.remove('pre-left','pre-right');
+                        next.removeEventListener('transitionend', cleanNext);
+                    }
+                });
+            } else {
+                // cut
+                previous.classList.remove('active');
+                next.classList.add('active');
+            }
+            updateCounter();
+            updateHash(true);
+            announce();
+        }
+
+        // navigate carries out a navigation action: next, previous, right,
+        // left, down or up
+        function navigate(action) {
+            setFollowing(false);
+            const to = move(action, currentSlide, currentStep, positions, n => slideSteps(slides[n]).length);
+            if (!to) {
+                return;
+            }
+            if (to.slide === currentSlide && !to.dir) {
+                showStep(to.step);
+            } else {
+                showSlide(to.slide, to.dir, to.step);
+            }
+        }
+
+        function nextSlide() {
+            navigate('next');
+        }
+
+        function previousSlide() {
+            navigate('previous');
+        }
+
+        // Overview: every slide as a thumbnail. When the deck has sub-slides,
+        // sections sit side by side with their sub-slides below; otherwise the
+        // tiles wrap like text. Click a tile, or pick one with the arrow keys
+        // and press Enter, to jump to it.
+        const hasSubSlides = positions.some(p => p.sub > 0);
+        let selectedTile = 0;
+
+        function toggleOverview(on) {
+            const overview = document.getElementById('overview');
+            overview.innerHTML = '';
+            overview.classList.toggle('flow', !hasSubSlides);
+            if (on) {
+                slides.forEach((slide, i) => {
+                    const tile = document.createElement('div');
+                    tile.className = 'overview-tile' + (i === currentSlide ? ' current' : '');
+                    if (hasSubSlides) {
+                        tile.style.gridColumn = positions[i].section;
+                        tile.style.gridRow = positions[i].sub + 1;
+                    }
+                    const copy = slide.cloneNode(true);
+                    copy.removeAttribute('id');
+                    copy.classList.remove('pre-left', 'pre-right', 'exiting-left', 'exiting-right');
+                    copy.classList.add('active');
+                    copy.querySelectorAll('.fragment').forEach(el => el.classList.add('visible'));
+                    tile.appendChild(copy);
+                    const caption = document.createElement('span');
+                    caption.className = 'overview-label';
+                    caption.textContent = label(i);
+                    tile.appendChild(caption);
+                    tile.addEventListener('click', function() {
+                        jumpTo(i);
+                    });
+                    overview.appendChild(tile);
+                });
+            }
+            overview.hidden = !on;
+            if (on) {
+                selectTile(currentSlide);
+            }
+        }
+
+        function selectTile(n) {
+            const tiles = document.getElementById('overview').children;
+            selectedTile = Math.min(Math.max(n, 0), totalSlides - 1);
+            Array.from(tiles).forEach((tile, i) => tile.classList.toggle('selected', i === selectedTile));
+            tiles[selectedTile].scrollIntoView({ block: 'nearest' });
+        }
+
+        // The tile an arrow key moves the selection to: through the sections
+        // and sub-slides of the grid, or through the rows of wrapped tiles
+        function tileTowards(key) {
+            const i = selectedTile;
+            if (hasSubSlides) {
+                if (key === 'ArrowDown') {
+                    return i + 1 < totalSlides && positions[i + 1].sub > 0 ? i + 1 : i;
+                }
+                if (key === 'ArrowUp') {
+                    return positions[i].sub > 0 ? i - 1 : i;
+                }
+                const section = positions[i].section + (key === 'ArrowRight' ? 1 : -1);
+                const n = positions.findIndex(p => p.section === section);
+                return n < 0 ? i : n;
+            }
+            const columns = getComputedStyle(document.getElementById('overview')).gridTemplateColumns.split(' ').length;
+            return i + { ArrowRight: 1, ArrowLeft: -1, ArrowDown: columns, ArrowUp: -columns }[key];
+        }
+
+        // jumpTo leaves the overview for slide n with none of its steps revealed
+        function jumpTo(n) {
+            toggleOverview(false);
+            setFollowing(false);
+            showSlide(n, n >= currentSlide ? 1 : -1, 0);
+        }
+
+        // Typing a slide's label, like 12 or 3.2, and pressing Enter jumps there
+        let jumpLabel = '';
+
+        function setJumpLabel(text) {
+            jumpLabel = text;
+            document.getElementById('jump').textContent = text ? ' → ' + text : '';
+        }
+
+        // Search: "/" or Ctrl+F opens a box that finds slides by their text.
+        // With a server behind the page it searches code and speaker notes
+        // too, and in -dir mode every deck; otherwise it searches this page.
+        const searchURL = {{.SearchURL}};
+        let searchResults = [];
+        let selectedResult = 0;
+        let searchTimer = null;
+
+        function toggleSearch(on) {
+            const input = document.getElementById('search-input');
+            document.getElementById('search').hidden = !on;
+            if (on) {
+                toggleOverview(false);
+                input.value = '';
+                showResults([]);
+                input.focus();
+            } else {
+                input.blur();
+            }
+        }
+
+        function searchPage(query) {
+            const terms = query.toLowerCase().split(/\s+/).filter(Boolean);
+            const results = [];
+            slides.forEach((slide, i) => {
+                const text = slide.textContent.replace(/\s+/g, ' ').trim();
+                const lower = text.toLowerCase();
+                if (!terms.every(term => lower.includes(term))) {
+                    return;
+                }
+                const at = lower.indexOf(terms[0]);
+                const from = Math.max(at - 40, 0);
+                const to = Math.min(at + terms[0].length + 80, text.length);
+                const heading = slide.querySelector('h1, h2, h3, h4, h5, h6');
+                results.push({
+                    number: i + 1,
+                    label: label(i),
+                    title: heading ? heading.textContent : '',
+                    snippet: (from > 0 ? '…' : '') + text.slice(from, to) + (to < text.length ? '…' : ''),
+                    url: '#' + label(i)
+                });
+            });
+            return results;
+        }
+
+        function runSearch(query) {
+            if (!query.trim()) {
+                showResults([]);
+            } else if (!searchURL) {
+                showResults(searchPage(query));
+            } else {
+                fetch(searchURL + '?q=' + encodeURIComponent(query))
+                    .then(response => response.json())
+                    .then(results => {
+                        if (document.getElementById('search-input').value === query) {
+                            showResults(results);
+                        }
+                    });
+            }
+        }
+
+        // highlightTerms appends text to el with the search terms marked
+        function highlightTerms(el, text, query) {
+            const terms = query.trim().split(/\s+/).map(term => term.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'));
+            text.split(new RegExp('(' + terms.join('|') + ')', 'i')).forEach((part, i) => {
+                if (i % 2) {
+                    const mark = document.createElement('mark');
+                    mark.textContent = part;
+                    el.appendChild(mark);
+                } else {
+                    el.appendChild(document.createTextNode(part));
+                }
+            });
+        }
+
+        function showResults(results) {
+            const query = document.getElementById('search-input').value;
+            const list = document.getElementById('search-results');
+            list.innerHTML = '';
+            searchResults = results;
+            results.forEach((result, i) => {
+                const item = document.createElement('li');
+                const title = document.createElement('div');
+                title.className = 'result-title';
+                title.textContent = (result.deck ? result.deck + ' · ' : '') + result.label + (result.title ? ' · ' + result.title : '') + (result.in_notes ? ' (notes)' : '');
+                const text = document.createElement('div');
+                text.className = 'result-snippet';
+                highlightTerms(text, result.snippet, query);
+                item.appendChild(title);
+                item.appendChild(text);
+                item.addEventListener('click', () => openResult(result));
+                list.appendChild(item);
+            });
+            selectResult(0);
+        }
+
+        function selectResult(n) {
+            const items = document.getElementById('search-results').children;
+            selectedResult = Math.min(Math.max(n, 0), items.length - 1);
+            Array.from(items).forEach((item, i) => item.classList.toggle('selected', i === selectedResult));
+            if (items[selectedResult]) {
+                items[selectedResult].scrollIntoView({ block: 'nearest' });
+            }
+        }
+
+        // openResult jumps to a match, loading its deck first if it's another one
+        function openResult(result) {
+            const url = new URL(result.url, location.href);
+            if (url.pathname !== location.pathname) {
+                location.href = url.href;
+                return;
+            }
+            toggleSearch(false);
+            const n = slideIndex(result.label);
+            if (n >= 0) {
+                jumpTo(n);
+            }
+        }
+
+        document.getElementById('search-input').addEventListener('input', function() {
+            clearTimeout(searchTimer);
+            searchTimer = setTimeout(() => runSearch(this.value), 150);
+        });
+
+        document.getElementById('search-input').addEventListener('keydown', function(e) {
+            if (e.key === 'Escape') {
+                toggleSearch(false);
+            } else if (e.key === 'ArrowDown') {
+                selectResult(selectedResult + 1);
+            } else if (e.key === 'ArrowUp') {
+                selectResult(selectedResult - 1);
+            } else if (e.key === 'Enter' && searchResults[selectedResult]) {
+                openResult(searchResults[selectedResult]);
+            } else {
+                return;
+            }
+            e.preventDefault();
+        });
+
+        // Keyboard navigation
+        document.addEventListener('keydown', function(e) {
+            if (!document.getElementById('search').hidden) {
+                return;
+            }
+            if (e.key === '/' || ((e.ctrlKey || e.metaKey) && (e.key === 'f' || e.key === 'F'))) {
+                e.preventDefault();
+                setJumpLabel('');
+                toggleSearch(true);
+                return;
+            }
+            if (e.ctrlKey || e.metaKey || e.altKey) {
+                return;
+            }
+            const overview = !document.getElementById('overview').hidden;
+            if (/^[0-9.]$/.test(e.key)) {
+                setJumpLabel(jumpLabel + e.key);
+                return;
+            }
+            if (jumpLabel) {
+                const target = jumpLabel;
+                if (e.key === 'Backspace') {
+                    setJumpLabel(target.slice(0, -1));
+                    return;
+                }
+                setJumpLabel('');
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    const n = slideIndex(target);
+                    if (n >= 0) {
+                        jumpTo(n);
+                    }
+                    return;
+                }
+                if (e.key === 'Escape') {
+                    return;
+                }
+            }
+
+            if (e.key === 'o' || e.key === 'O' || e.key === 'Escape') {
+                toggleOverview(!overview);
+            } else if (overview) {
+                if (e.key.startsWith('Arrow')) {
+                    selectTile(tileTowards(e.key));
+                } else if (e.key === 'Home') {
+                    selectTile(0);
+                } else if (e.key === 'End') {
+                    selectTile(totalSlides - 1);
+                } else if (e.key === 'Enter') {
+                    jumpTo(selectedTile);
+                } else {
+                    return;
+                }
+                e.preventDefault();
+            } else if (navigationKeys[e.key]) {
+                navigate(navigationKeys[e.key]);
+            } else if (e.key === 'Home') {
+                jumpTo(0);
+            } else if (e.key === 'End') {
+                jumpTo(totalSlides - 1);
+            } else if (e.key === 'f' || e.key === 'F') {
+                setFollowing(!following);
+            {{if .PresenterURL}}
+            } else if (e.key === 'p' || e.key === 'P') {
+                window.open('{{.PresenterURL}}', 'slides-presenter:' + deckKey);
+            {{end}}
+            }
+        });
+
+        // Watermark drift animation
+        (function() {
+            const interval = {{.Watermark.MoveMs}};
+            if (interval && interval > 0) {
+                let offset = 0;
+                setInterval(() => {
+                    offset = (offset + 12) % 96;
+                    const wm = document.getElementById('wm-texts');
+                    if (wm) {
+                        wm.style.transform = 'translate(-50%, -50%) rotate(-25deg) translate(' + offset + 'px, ' + offset + 'px)';
+                    }
+                }, interval);
+            }
+        })();
+
+        // Initialize on the slide the URL names: the hash, then a ?slide=
+        // link. The hash survives reloads, so live reload lands here too.
+        let start = parseHash(location.hash);
+        if (!start && params.has('slide')) {
+            const n = slideIndex(params.get('slide'));
+            start = n < 0 ? null : { slide: n, step: parseInt(params.get('step') || '', 10) || 0 };
+        }
+        if (params.has('slide') || params.has('step')) {
+            params.delete('slide');
+            params.delete('step');
+            history.replaceState(null, '', location.pathname + (params.toString() ? '?' + params : '') + location.hash);
+        }
+        start = start || { slide: 0, step: 0 };
+        slides.forEach(slide => slide.classList.remove('active'));
+        currentSlide = start.slide;
+        showSlide(start.slide, 1, start.step);
+        setFollowing(following);
+
+        {{if not .Static}}
+        // Server events: "state" when the driver moves, and "reload" whenever
+        // the deck changes in -watch mode
+        (function() {
+            const source = new EventSource('{{.Base}}events');
+            source.addEventListener('state', function(e) {
+                driverState = JSON.parse(e.data);
+                setFollowing(following);
+            });
+            {{if .LiveReload}}
+            source.addEventListener('reload', function() {
+                location.reload();
+            });
+            {{end}}
+        })();
+        {{end}}
+    </script>
+</body>
+</html>`
+
+	t := template.Must(template.New("slides").Parse(tmpl + stepScript + navigationScript))
+	data := struct {
+		Title          string
+		DeckTitle      string
+		Logo           string
+		Classification struct {
+			Label string
+			Bg    string
+			Fg    string
+		}
+		Transition string
+		Watermark  struct {
+			Enabled bool
+			Text    string
+			Opacity string
+			Repeat  []int
+			MoveMs  int
+		}
+		Slides       []deck.Slide
+		Sections     int
+		Start        deck.Slide
+		Base         string
+		Static       bool
+		LiveReload   bool
+		PresenterURL string
+		SearchURL    string
+		InlineCSS    template.CSS
+	}{}
+
+	data.Title = pageTitle
+	data.DeckTitle = pageTitle
+	data.Logo = d.AssetPath(theme.Logo)
+	data.Classification.Label = theme.ClassificationLabel
+	data.Classification.Bg, data.Classification.Fg = classificationColors(theme)
+	data.Transition = transition
+	// Watermark
+	if theme.Watermark {
+		data.Watermark.Enabled = true
+		data.Watermark.Text = watermarkText(theme, data.DeckTitle)
+		// clamp opacity
+		op := theme.WatermarkOpacity
+		if op <= 0 || op > 1 {
+			op = 0.08
+		}
+		data.Watermark.Opacity = fmt.Sprintf("%.2f", op)
+		// prepare repetition tiles
+		rep := 96
+		data.Watermark.Repeat = make([]int, rep)
+		for i := 0; i < rep; i++ {
+			data.Watermark.Repeat[i] = i
+		}
+		if theme.WatermarkMoveSeconds > 0 {
+			data.Watermark.MoveMs = theme.WatermarkMoveSeconds * 1000
+		}
+	}
+	data.Slides = slides
+	data.Sections = d.Sections()
+	if opts.Start > 0 && opts.Start <= len(slides) {
+		data.Start = slides[opts.Start-1]
+	} else if len(slides) > 0 {
+		data.Start = slides[0]
+	}
+	data.Base = opts.Base
+	data.Static = opts.Static
+	data.LiveReload = opts.LiveReload
+	data.PresenterURL = opts.presenterURL()
+	data.SearchURL = opts.SearchURL
+	if opts.SingleFile {
+		data.InlineCSS = template.CSS(CSS(theme))
+	}
+
+	// Inject opacity constant into CSS (simple string replace) after data populated
+	if theme.Watermark {
+		op := data.Watermark.Opacity
+		if op == "" {
+			op = "0.08"
+		}
+		tmpl = strings.ReplaceAll(tmpl, "OP", op)
+		t = template.Must(template.New("slides").Parse(tmpl + stepScript + navigationScript))
+	}
+
+	return t.Execute(w, data)
+}
@@ -0,0 +1,100 @@
+package render
+
+// stepScript defines the "steps" template, the reveal step logic shared by
+// the audience and presenter views so both count and show steps alike.
+const stepScript = `{{define "steps"}}// Reveal steps in document order: one per fragment ("+" list items and
+        // blocks after a ". . ." pause) and one per further line set of a
+        // {1|3-4|6} code block
+        function slideSteps(slide) {
+            const steps = [];
+            slide.querySelectorAll('.fragment, pre[data-line-steps]').forEach(el => {
+                if (el.classList.contains('fragment')) {
+                    steps.push({ el: el });
+                    return;
+                }
+                const count = JSON.parse(el.dataset.lineSteps).length;
+                for (let i = 1; i < count; i++) {
+                    steps.push({ el: el, line: i });
+                }
+            });
+            return steps;
+        }
+
+        function setLineStep(pre, step) {
+            const lines = JSON.parse(pre.dataset.lineSteps)[step];
+            pre.querySelectorAll('.line').forEach(line => {
+                line.classList.toggle('highlighted', lines.includes(parseInt(line.dataset.line, 10)));
+            });
+        }
+
+        // applyStep shows the first n reveal steps of a slide
+        function applyStep(slide, n) {
+            slide.querySelectorAll('.fragment').forEach(el => el.classList.remove('visible'));
+            slide.querySelectorAll('pre[data-line-steps]').forEach(pre => setLineStep(pre, 0));
+            slideSteps(slide).slice(0, n).forEach(step => {
+                if (step.line === undefined) {
+                    step.el.classList.add('visible');
+                } else {
+                    setLineStep(step.el, step.line);
+                }
+            });
+        }{{end}}`
+
+// navigationScript defines the "navigation" template, which works out where
+// the navigation keys lead so the presenter moves the way the audience view
+// does. It expects slideSteps from the "steps" template.
+const navigationScript = `{{define "navigation"}}// Where each slide sits: its section (top-level slide) and its index
+        // among that section's sub-slides, 0 for the top-level slide itself
+        function slidePositions(elements) {
+            return Array.from(elements).map(el => ({
+                section: parseInt(el.dataset.section, 10),
+                sub: parseInt(el.dataset.sub, 10)
+            }));
+        }
+
+        // move returns the slide and step a navigation action leads to from
+        // slide n at step, or null when it leads nowhere. Every action walks
+        // through the reveal steps first. Then "next" and "previous" go
+        // through the slides in reading order, "right" and "left" between
+        // sections and "down" and "up" through a section's sub-slides.
+        // Going back lands on a slide with all its steps revealed.
+        function move(action, n, step, positions, stepCount) {
+            const count = positions.length;
+            const forward = action === 'next' || action === 'right' || action === 'down';
+            if (forward && step < stepCount(n)) {
+                return { slide: n, step: step + 1, dir: 0 };
+            }
+            if (!forward && step > 0) {
+                return { slide: n, step: step - 1, dir: 0 };
+            }
+            let target = n;
+            if (action === 'next') {
+                target = (n + 1) % count;
+            } else if (action === 'previous') {
+                target = (n + count - 1) % count;
+            } else if (action === 'right') {
+                target = n + 1;
+                while (target < count && positions[target].sub > 0) target++;
+                if (target >= count) target = 0;
+            } else if (action === 'left') {
+                target = n - positions[n].sub - 1;
+                if (target < 0) target = count - 1;
+                while (positions[target].sub > 0) target--;
+            } else if (action === 'down') {
+                if (n + 1 >= count || positions[n + 1].sub === 0) return null;
+                target = n + 1;
+            } else if (action === 'up') {
+                if (positions[n].sub === 0) return null;
+                target = n - 1;
+            }
+            return { slide: target, step: forward ? 0 : stepCount(target), dir: forward ? 1 : -1 };
+        }
+
+        // The keys both views navigate with
+        const navigationKeys = {
+            ' ': 'next',
+            'ArrowRight': 'right',
+            'ArrowLeft': 'left',
+            'ArrowDown': 'down',
+            'ArrowUp': 'up'
+        };{{end}}`
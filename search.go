@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"slides/deck"
+)
+
+// maxSearchResults caps how many slides one search returns.
+const maxSearchResults = 50
+
+// searchResult is a slide matching a search, as /api/search returns it.
+type searchResult struct {
+	Deck    string `json:"deck,omitempty"` // deck name in -dir mode
+	Number  int    `json:"number"`         // position in reading order, from 1
+	Label   string `json:"label"`          // as the counter shows it, e.g. "3.2"
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+	InNotes bool   `json:"in_notes,omitempty"` // matched only in the speaker notes
+	URL     string `json:"url"`
+}
+
+// searchDeck finds the slides whose text, code or notes contain every word
+// of query, ignoring case. base is the URL the deck is served under.
+func searchDeck(deck *deck.Deck, query, base string) []searchResult {
+	terms := strings.Fields(strings.Map(unicode.ToLower, query))
+	if len(terms) == 0 {
+		return nil
+	}
+	var results []searchResult
+	for _, slide := range deck.Slides {
+		text := strings.Join(strings.Fields(slide.Text), " ")
+		notes := strings.Join(strings.Fields(slide.NotesText), " ")
+		all := strings.Map(unicode.ToLower, text+" "+notes)
+		matched := true
+		for _, term := range terms {
+			if !strings.Contains(all, term) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		r := searchResult{
+			Number: slide.Number,
+			Label:  slide.Label(),
+			Title:  slide.Title,
+			URL:    base + "#" + slide.Label(),
+		}
+		if r.Snippet = snippet(text, terms[0]); r.Snippet == "" {
+			r.Snippet, r.InNotes = snippet(notes, terms[0]), true
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// snippet is the part of text around the first match of term, which must
+// be lower case, or "" when term doesn't occur.
+func snippet(text, term string) string {
+	runes := []rune(text)
+	lower := []rune(strings.Map(unicode.ToLower, text))
+	at := strings.Index(string(lower), term)
+	if at < 0 {
+		return ""
+	}
+	at = len([]rune(string(lower)[:at]))
+	from, to := max(at-40, 0), min(at+len([]rune(term))+80, len(runes))
+	s := string(runes[from:to])
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+// serveSearch answers /api/search?q= for the deck.
+func (s *server) serveSearch(w http.ResponseWriter, r *http.Request) {
+	writeSearchResults(w, searchDeck(s.current(), r.URL.Query().Get("q"), s.base))
+}
+
+// serveSearch answers /api/search?q= across every deck of the library.
+func (l *library) serveSearch(w http.ResponseWriter, r *http.Request) {
+	l.mu.RLock()
+	decks := make([]*libraryDeck, 0, len(l.decks))
+	for _, d := range l.decks {
+		if d.srv != nil {
+			decks = append(decks, d)
+		}
+	}
+	l.mu.RUnlock()
+	sort.Slice(decks, func(i, j int) bool { return decks[i].name < decks[j].name })
+
+	var results []searchResult
+	for _, d := range decks {
+		for _, result := range searchDeck(d.srv.current(), r.URL.Query().Get("q"), d.srv.base) {
+			result.Deck = d.name
+			results = append(results, result)
+		}
+	}
+	writeSearchResults(w, results)
+}
+
+func writeSearchResults(w http.ResponseWriter, results []searchResult) {
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+	if results == nil {
+		results = []searchResult{}
+	}
+	writeJSON(w, results)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"slides/deck"
+	"slides/render"
+)
+
+// server holds the currently loaded deck so it can be swapped out while
+// requests are being served, and the shared presentation state that
+// followers track.
+type server struct {
+	cfgPath     string
+	mdPath      string
+	theme       string
+	base        string // URL path the deck is served under, ending in "/"
+	searchURL   string // what the search box queries; the deck's own /api/search if empty
+	assetBase   string // where relative asset paths point; "/assets/" if empty
+	assetDir    string
+	talkLength  time.Duration
+	driverToken string
+	hub         *hub
+	watching    bool
+
+	mu     sync.RWMutex
+	deck   *deck.Deck
+	state  presentationState
+	driven bool
+}
+
+// presentationState is where the driver currently is: the slide and how
+// many of its reveal steps (fragments and code line sets) are shown. It is
+// broadcast to every follower as a "state" event.
+type presentationState struct {
+	Slide int `json:"slide"`
+	Step  int `json:"step"`
+}
+
+// reload re-runs the parsing pipeline and swaps in the new deck. On error the
+// previously loaded deck keeps being served.
+func (s *server) reload() error {
+	deck, err := loadDeck(s.cfgPath, s.mdPath, s.theme, s.assetBase)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.deck = deck
+	if s.state.Slide >= len(deck.Slides) {
+		s.state = presentationState{}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *server) current() *deck.Deck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deck
+}
+
+func (s *server) currentState() presentationState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// routes builds the handler tree for a single deck.
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	opts := render.Options{Base: s.base, LiveReload: s.watching, TalkLength: s.talkLength, SearchURL: s.searchURL}
+	if opts.SearchURL == "" {
+		opts.SearchURL = s.base + "api/search"
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		deck := s.current()
+		opts := opts
+		opts.Start = deck.SlideNumber(r.URL.Query().Get("slide"))
+		if err := render.HTML(w, deck, opts); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/presenter", func(w http.ResponseWriter, r *http.Request) {
+		if err := render.Presenter(w, s.current(), opts); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, render.CSS(s.current().Theme))
+	})
+
+	mux.HandleFunc("/events", s.serveEvents)
+	mux.HandleFunc("/api/state", s.serveState)
+	mux.HandleFunc("/api/search", s.serveSearch)
+	mux.HandleFunc("/api/deck", s.serveDeckAPI)
+	mux.HandleFunc("/api/slides/", s.serveSlideAPI)
+
+	// Static assets from the markdown file directory, served under /assets/
+	if s.assetDir != "" {
+		fs := http.FileServer(http.Dir(s.assetDir))
+		mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
+	}
+
+	return mux
+}
+
+// serveEvents subscribes the client to the hub. Once a driver has moved the
+// presentation, late joiners get the current state first so they land on
+// the driver's slide.
+func (s *server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	driven, state := s.driven, s.state
+	s.mu.RUnlock()
+	if !driven {
+		s.hub.serveEvents(w, r)
+		return
+	}
+	s.hub.serveEvents(w, r, stateEvent(state))
+}
+
+// serveState reports the presentation state on GET and lets the driver move
+// it on POST. Drivers authenticate with "Authorization: Bearer <token>".
+func (s *server) serveState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.currentState())
+	case http.MethodPost:
+		if !s.isDriver(r) {
+			http.Error(w, "driver token required", http.StatusUnauthorized)
+			return
+		}
+		var next presentationState
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&next); err != nil {
+			http.Error(w, "invalid state: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		if next.Slide < 0 || next.Slide >= len(s.deck.Slides) {
+			s.mu.Unlock()
+			http.Error(w, fmt.Sprintf("slide %d out of range", next.Slide), http.StatusBadRequest)
+			return
+		}
+		if next.Step < 0 {
+			s.mu.Unlock()
+			http.Error(w, fmt.Sprintf("step %d out of range", next.Step), http.StatusBadRequest)
+			return
+		}
+		changed := s.state != next || !s.driven
+		s.state = next
+		s.driven = true
+		s.mu.Unlock()
+
+		if changed {
+			s.hub.publish(stateEvent(next))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) isDriver(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || s.driverToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.driverToken)) == 1
+}
+
+func stateEvent(state presentationState) event {
+	data, _ := json.Marshal(state)
+	return event{Name: "state", Data: string(data)}
+}
+
+// event is a single Server-Sent Event pushed to connected browsers.
+type event struct {
+	Name string
+	Data string
+}
+
+// hub fans events out to every subscribed client.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[chan event]struct{})}
+}
+
+func (h *hub) subscribe() chan event {
+	ch := make(chan event, 8)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+// publish delivers ev to all subscribers. Slow clients that have a full
+// buffer miss the event rather than blocking everyone else.
+func (h *hub) publish(ev event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// serveEvents streams hub events to the browser as text/event-stream. The
+// initial events are sent straight after connecting.
+func (h *hub) serveEvents(w http.ResponseWriter, r *http.Request, initial ...event) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	// Keep-alive comments stop proxies from closing idle connections
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+
+	fmt.Fprint(w, ": connected\n\n")
+	for _, ev := range initial {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case ev := <-ch:
+			writeEvent(w, ev)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, ev event) {
+	fmt.Fprintf(w, "event: %s\n", ev.Name)
+	for _, line := range strings.Split(ev.Data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
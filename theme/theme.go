@@ -0,0 +1,51 @@
+// Package theme describes how decks look: the themes configuration file
+// maps names to CSS, banners, watermarks and slides added to every deck.
+package theme
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is one entry of the configuration. Frontmatter can override its
+// transition, classification label and watermark per deck.
+type Theme struct {
+	Name                 string            `yaml:"name"`
+	CSS                  string            `yaml:"css"`
+	Title                string            `yaml:"title"`
+	Logo                 string            `yaml:"logo"`
+	ClassificationLabel  string            `yaml:"classification_label"`
+	ClassificationBg     string            `yaml:"classification_bg"`
+	ClassificationFg     string            `yaml:"classification_fg"`
+	Transition           string            `yaml:"transition"`
+	Watermark            bool              `yaml:"watermark"`
+	WatermarkText        string            `yaml:"watermark_text"`
+	WatermarkOpacity     float64           `yaml:"watermark_opacity"`
+	WatermarkAppendDate  bool              `yaml:"watermark_append_date"`
+	WatermarkMoveSeconds int               `yaml:"watermark_move_seconds"`
+	FirstSlide           string            `yaml:"first_slide"`
+	LastSlide            string            `yaml:"last_slide"`
+	Syntax               map[string]string `yaml:"syntax"` // token kind → color for code highlighting
+}
+
+// Config is the themes configuration file, themes.yaml or slides.md.yaml.
+type Config struct {
+	Themes map[string]Theme `yaml:"themes"`
+}
+
+// LoadConfig reads the themes configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
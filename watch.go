@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchFiles polls the files listed by files and everything under assetDir,
+// calling onChange whenever a modification time or size changes. files is
+// asked again on every poll, so it can follow a deck's includes as they
+// change. Polling keeps us free of platform-specific notification APIs.
+func watchFiles(files func() []string, assetDir string, interval time.Duration, onChange func()) {
+	last := snapshotFiles(files(), assetDir)
+	for range time.Tick(interval) {
+		next := snapshotFiles(files(), assetDir)
+		if !sameSnapshot(last, next) {
+			onChange()
+		}
+		last = next
+	}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func snapshotFiles(files []string, assetDir string) map[string]fileStamp {
+	snap := make(map[string]fileStamp)
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			snap[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+	filepath.WalkDir(assetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		// Skip hidden directories such as .git
+		if d.IsDir() && path != assetDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			snap[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return snap
+}
+
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		other, ok := b[path]
+		if !ok || !other.modTime.Equal(stamp.modTime) || other.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}